@@ -9,13 +9,26 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/go-inspire/pkg/app/registry"
+	"github.com/go-inspire/pkg/app/server"
+	"github.com/go-inspire/pkg/config"
+	"github.com/go-inspire/pkg/log"
 	"github.com/google/uuid"
 	"golang.org/x/sync/errgroup"
-	"log"
 	"os"
-	"os/signal"
 	"sync"
 	"syscall"
+	"time"
+)
+
+var (
+	// ErrStopTimeout is returned by Run when servers did not stop within the StopTimeout.
+	ErrStopTimeout = errors.New("app: stop timeout")
+	// ErrForcedStop is returned by Run when a second signal aborts a graceful stop.
+	ErrForcedStop = errors.New("app: forced stop")
+	// ErrNotReady is returned by Run when servers did not become ready within the ReadyTimeout.
+	ErrNotReady = errors.New("app: server not ready")
 )
 
 // AppInfo is application context value.
@@ -24,13 +37,23 @@ type AppInfo interface {
 	Name() string
 	Version() string
 	Metadata() map[string]string
+	Endpoints() []string
 }
 
 // App is an application components lifecycle manager
 type App struct {
-	opts   options
-	ctx    context.Context
-	cancel func()
+	opts     options
+	ctx      context.Context
+	cancel   func()
+	phases   [][]int
+	policies map[int]RestartPolicy
+	err      error
+
+	startTime time.Time
+	reloadMu  sync.Mutex
+
+	instMu   sync.Mutex // serializes the registration and the deregistration
+	instance *registry.ServiceInstance
 }
 
 // ID returns app instance id.
@@ -45,11 +68,39 @@ func (a *App) Version() string { return a.opts.version }
 // Metadata returns service metadata.
 func (a *App) Metadata() map[string]string { return a.opts.metadata }
 
+// Endpoints returns the service endpoints: the ones set with Endpoint if any,
+// otherwise the endpoints of the started servers implementing server.Endpointer.
+func (a *App) Endpoints() []string {
+	if len(a.opts.endpoints) > 0 {
+		endpoints := make([]string, 0, len(a.opts.endpoints))
+		for _, u := range a.opts.endpoints {
+			endpoints = append(endpoints, u.String())
+		}
+		return endpoints
+	}
+	var endpoints []string
+	for _, srv := range a.opts.servers {
+		if e, ok := srv.(server.Endpointer); ok {
+			for _, u := range e.Endpoints() {
+				endpoints = append(endpoints, u.String())
+			}
+		}
+	}
+	return endpoints
+}
+
+// Servers returns the registered servers.
+func (a *App) Servers() []server.Server {
+	return append([]server.Server(nil), a.opts.servers...)
+}
+
 // New create an application lifecycle manager.
 func New(opts ...Option) *App {
 	options := options{
-		ctx:  context.Background(),
-		sigs: []os.Signal{syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT},
+		ctx:              context.Background(),
+		sigs:             []os.Signal{syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT},
+		readyTimeout:     30 * time.Second,
+		registrarTimeout: 10 * time.Second,
 	}
 	if id, err := uuid.NewUUID(); err == nil {
 		options.id = id.String()
@@ -58,52 +109,324 @@ func New(opts ...Option) *App {
 		o(&options)
 	}
 	ctx, cancel := context.WithCancel(options.ctx)
+	phases, err := phases(options.servers, options.deps)
+	policies, perr := policies(options.servers, options.supervisions)
 	return &App{
-		opts:   options,
-		ctx:    ctx,
-		cancel: cancel,
+		opts:     options,
+		ctx:      ctx,
+		cancel:   cancel,
+		phases:   phases,
+		policies: policies,
+		err:      errors.Join(err, perr),
 	}
 }
 
-// Run executes all OnStart hooks registered with the application's Lifecycle.
-func (a *App) Run() error {
+// Run starts the servers phase by phase in dependency order, waiting for the
+// servers of a phase implementing server.Readier to be ready before starting
+// the next one. It then waits for a stop signal and stops the started phases
+// in reverse order; other signals are passed to their SignalHandler.
+//
+// It returns ErrDependencyCycle if the declared dependencies contain a cycle.
+// Otherwise the failures of the run are reported by a *RunError, where each
+// failing server is reported by a *ServerError: ErrNotReady if it missed the
+// ReadyTimeout, ErrRestartExhausted if it exhausted its restart budget and
+// ErrStopTimeout if it missed the StopTimeout. ErrForcedStop is reported if a
+// second signal arrives while the application is stopping.
+//
+// With a Registrar, the app instance is registered once all servers are ready
+// and deregistered before the BeforeStop hooks run.
+//
+// The BeforeStart hooks run before any server starts and abort Run on error,
+// the AfterStart hooks run once all servers started, the BeforeStop hooks run
+// before the first server stops and the AfterStop hooks always run last.
+// Signals are handled from before the BeforeStart hooks, a stop signal
+// received while the servers start stops the ones already started.
+func (a *App) Run() (err error) {
+	if a.err != nil {
+		return a.err
+	}
+	a.startTime = time.Now()
+	a.logw(log.InfoLevel, "app starting", "servers", len(a.opts.servers))
 	ctx := NewContext(a.ctx, a)
+	if a.opts.config != nil {
+		ctx = config.NewContext(ctx, a.opts.config)
+	}
+	// servers are stopped with a fresh context, so they can tell a graceful
+	// drain from the forced stop, which cancels stopCtx.
+	stopCtx, stopCancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopCancel()
+	errs := &errorList{}
+	defer func() {
+		errs.add(runHooks(stopCtx, a.opts.afterStop, false))
+		if err = errs.result(); err != nil {
+			a.logw(log.ErrorLevel, "app stopped", "uptime", time.Since(a.startTime).String(), "error", err)
+		} else {
+			a.logw(log.InfoLevel, "app stopped", "uptime", time.Since(a.startTime).String())
+		}
+	}()
 	eg, ctx := errgroup.WithContext(ctx)
-	wg := sync.WaitGroup{}
-	for _, srv := range a.opts.servers {
-		srv := srv
-		eg.Go(func() error {
-			<-ctx.Done() // wait for stop signal
-			return srv.Stop(ctx)
-		})
-		wg.Add(1)
-		eg.Go(func() error {
-			wg.Done()
-			return srv.Start(ctx)
-		})
-	}
-	wg.Wait()
+
+	// signals are handled from the start: a stop signal received while the
+	// servers start aborts the startup instead of killing the process
+	handlers := a.handlers()
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, a.opts.sigs...)
-	eg.Go(func() error {
+	notifier := a.opts.notifier
+	if notifier == nil {
+		notifier = osNotifier{}
+	}
+	notifier.Notify(c, a.opts.sigs...)
+	for sig := range handlers {
+		notifier.Notify(c, sig)
+	}
+	defer notifier.Stop(c)
+	exit, forced, handled := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(handled)
+		stopping, stopped := false, ctx.Done()
 		for {
 			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-c:
-				return a.Stop()
+			case <-exit:
+				return
+			case <-stopped:
+				stopping, stopped = true, nil
+			case sig := <-c:
+				if h, ok := handlers[sig]; ok {
+					go h(ctx, sig)
+					continue
+				}
+				if stopping {
+					stopCancel()
+					a.logw(log.WarnLevel, "forced stop requested", "signal", sig.String())
+					errs.add(ErrForcedStop)
+					close(forced)
+					return
+				}
+				stopping = true
+				a.logw(log.InfoLevel, "stop requested", "signal", sig.String())
+				_ = a.Stop()
 			}
 		}
+	}()
+	defer func() {
+		close(exit)
+		<-handled
+	}()
+
+	if e := runHooks(ctx, a.opts.beforeStart, true); e != nil {
+		errs.add(e)
+		return
+	}
+	started, failed := 0, false
+	for _, phase := range a.phases {
+		if ctx.Err() != nil {
+			break // a server of an earlier phase failed
+		}
+		exited := make([]chan struct{}, len(phase))
+		for k, i := range phase {
+			i, exit := i, make(chan struct{})
+			exited[k] = exit
+			eg.Go(func() error {
+				defer close(exit)
+				err := a.startServer(ctx, i)
+				if err != nil && !(errors.Is(err, context.Canceled) && ctx.Err() != nil) {
+					errs.add(&ServerError{Index: i, Name: server.Name(i, a.opts.servers[i]), Phase: PhaseStart, Err: err})
+				}
+				return err
+			})
+		}
+		started++
+		if pending := a.waitReady(ctx, phase, exited); len(pending) > 0 {
+			for _, i := range pending {
+				errs.add(&ServerError{Index: i, Name: server.Name(i, a.opts.servers[i]), Phase: PhaseStart, Err: ErrNotReady})
+			}
+			failed = true
+			_ = a.Stop()
+			break
+		}
+	}
+	eg.Go(func() error {
+		<-ctx.Done() // wait for stop signal
+		a.logw(log.InfoLevel, "app stopping", "cause", context.Cause(ctx).Error())
+		errs.add(a.deregister(stopCtx))
+		errs.add(runHooks(stopCtx, a.opts.beforeStop, false))
+		a.stopPhases(stopCtx, a.phases[:started], errs)
+		return nil
 	})
-	log.Printf("%s running...", a.Name())
+	if ctx.Err() == nil && !failed {
+		if err := a.register(ctx); err != nil {
+			errs.add(err)
+			failed = true
+			_ = a.Stop()
+		}
+	}
+	if ctx.Err() == nil && !failed {
+		if err := runHooks(ctx, a.opts.afterStart, true); err != nil {
+			errs.add(err)
+			_ = a.Stop()
+		}
+	}
 
-	if err := eg.Wait(); err != nil && !errors.Is(err, context.Canceled) {
-		return err
+	done := make(chan struct{})
+	go func() {
+		_ = eg.Wait() // the failures are collected in errs
+		close(done)
+	}()
+	if ctx.Err() == nil {
+		a.logw(log.InfoLevel, "app ready", "startup", time.Since(a.startTime).String(),
+			"endpoints", a.Endpoints())
+	}
+
+	select {
+	case <-done:
+	case <-forced:
+	}
+	return
+}
+
+// logger returns the logger of the app, log.Named("app") by default.
+func (a *App) logger() *log.Adapter {
+	if a.opts.logger != nil {
+		return a.opts.logger
+	}
+	return log.Named("app")
+}
+
+// logw logs a lifecycle event with the app ID, name and version attached.
+func (a *App) logw(level log.Level, msg string, keyvals ...interface{}) {
+	l := a.logger()
+	if !l.Enabled(level) {
+		return
+	}
+	kv := make([]interface{}, 0, len(keyvals)+6)
+	kv = append(kv, "app.id", a.ID(), "app.name", a.Name(), "app.version", a.Version())
+	l.Log(a.ctx, level, msg, append(kv, keyvals...)...)
+}
+
+// buildInstance returns the registry instance of the app.
+func (a *App) buildInstance() *registry.ServiceInstance {
+	return &registry.ServiceInstance{
+		ID:        a.ID(),
+		Name:      a.Name(),
+		Version:   a.Version(),
+		Metadata:  a.Metadata(),
+		Endpoints: a.Endpoints(),
+	}
+}
+
+// register registers the app instance with the Registrar, if any.
+// A registration aborted by the app stopping is not an error.
+func (a *App) register(ctx context.Context) error {
+	if a.opts.registrar == nil {
+		return nil
+	}
+	a.instMu.Lock()
+	defer a.instMu.Unlock()
+	rctx, cancel := withTimeout(ctx, a.opts.registrarTimeout)
+	defer cancel()
+	ins := a.buildInstance()
+	if err := a.opts.registrar.Register(rctx, ins); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		a.logw(log.ErrorLevel, "register failed", "endpoints", ins.Endpoints, "error", err)
+		return fmt.Errorf("app: register: %w", err)
+	}
+	a.instance = ins
+	a.logw(log.InfoLevel, "registered", "endpoints", ins.Endpoints)
+	return nil
+}
+
+// deregister deregisters the registered app instance, if any.
+func (a *App) deregister(ctx context.Context) error {
+	a.instMu.Lock()
+	defer a.instMu.Unlock()
+	if a.instance == nil {
+		return nil
+	}
+	ins := a.instance
+	a.instance = nil
+	ctx, cancel := withTimeout(ctx, a.opts.registrarTimeout)
+	defer cancel()
+	if err := a.opts.registrar.Deregister(ctx, ins); err != nil {
+		a.logw(log.ErrorLevel, "deregister failed", "error", err)
+		return fmt.Errorf("app: deregister: %w", err)
 	}
-	log.Printf("%s exit!!!", a.Name())
+	a.logw(log.InfoLevel, "deregistered")
 	return nil
 }
 
+// withTimeout returns ctx bounded by d, or ctx itself if d is zero.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
+
+// runHooks calls the hooks in order with ctx. If failFast is set it returns
+// the first error, otherwise all hooks run and their errors are joined.
+func runHooks(ctx context.Context, hooks []func(context.Context) error, failFast bool) error {
+	var errs []error
+	for _, fn := range hooks {
+		if err := fn(ctx); err != nil {
+			if failFast {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// waitReady waits until every server.Readier of the phase is ready or has
+// returned from Start. It returns the indexes of the servers that are still
+// not ready when the ReadyTimeout elapses.
+func (a *App) waitReady(ctx context.Context, phase []int, exited []chan struct{}) []int {
+	var timeout <-chan time.Time
+	if a.opts.readyTimeout > 0 {
+		t := time.NewTimer(a.opts.readyTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	for k, i := range phase {
+		r, ok := a.opts.servers[i].(server.Readier)
+		if !ok {
+			continue
+		}
+		select {
+		case <-r.Ready():
+		case <-exited[k]:
+		case <-ctx.Done():
+			return nil // the stop error is reported by the servers
+		case <-timeout:
+			var pending []int
+			for j := k; j < len(phase); j++ {
+				if !isReady(a.opts.servers[phase[j]], exited[j]) {
+					pending = append(pending, phase[j])
+				}
+			}
+			return pending
+		}
+	}
+	return nil
+}
+
+// isReady reports whether srv is ready or has returned from Start.
+func isReady(srv server.Server, exited chan struct{}) bool {
+	r, ok := srv.(server.Readier)
+	if !ok {
+		return true
+	}
+	select {
+	case <-r.Ready():
+		return true
+	case <-exited:
+		return true
+	default:
+		return false
+	}
+}
+
 // Stop gracefully stops the application.
 func (a *App) Stop() error {
 	if a.cancel != nil {
@@ -112,6 +435,59 @@ func (a *App) Stop() error {
 	return nil
 }
 
+// stopPhases stops the servers phase by phase in reverse start order and adds
+// their failures to errs, servers missing the StopTimeout fail with ErrStopTimeout.
+// Servers of the same phase are stopped concurrently, and a phase is only
+// stopped once every server of the later phases has returned from Stop
+// or missed its StopTimeout.
+func (a *App) stopPhases(ctx context.Context, phases [][]int, errs *errorList) {
+	for p := len(phases) - 1; p >= 0; p-- {
+		wg := sync.WaitGroup{}
+		for _, i := range phases[p] {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				srv := a.opts.servers[i]
+				begin := time.Now()
+				err := a.stopServer(ctx, srv)
+				if err == nil {
+					a.logw(log.InfoLevel, "server stopped", "server", server.Name(i, srv),
+						"duration", time.Since(begin).String())
+					return
+				}
+				a.logw(log.ErrorLevel, "server stop failed", "server", server.Name(i, srv),
+					"duration", time.Since(begin).String(), "error", err)
+				if errors.Is(err, context.DeadlineExceeded) {
+					err = fmt.Errorf("%w: %w", ErrStopTimeout, err)
+				}
+				errs.add(&ServerError{Index: i, Name: server.Name(i, srv), Phase: PhaseStop, Err: err})
+			}(i)
+		}
+		wg.Wait()
+	}
+}
+
+// stopServer calls srv.Stop with a context bounded by the StopTimeout.
+// It returns context.DeadlineExceeded without waiting further for Stop
+// once the timeout elapsed.
+func (a *App) stopServer(ctx context.Context, srv server.Server) error {
+	if a.opts.stopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.opts.stopTimeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Stop(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 type appKey struct{}
 
 // NewContext returns a new Context that carries value.
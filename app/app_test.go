@@ -0,0 +1,544 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/go-inspire/pkg/app/registry"
+	"github.com/go-inspire/pkg/config"
+	"github.com/go-inspire/pkg/log"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records lifecycle events of mock servers.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// mockServer is a server.Server that blocks in Start until stopped.
+type mockServer struct {
+	name  string
+	rec   *recorder
+	ready chan struct{}
+	stop  chan struct{}
+	once  sync.Once
+}
+
+func newMockServer(name string, rec *recorder) *mockServer {
+	return &mockServer{name: name, rec: rec, ready: make(chan struct{}), stop: make(chan struct{})}
+}
+
+func (s *mockServer) Start(ctx context.Context) error {
+	s.rec.add("start " + s.name)
+	close(s.ready)
+	<-s.stop
+	return nil
+}
+
+func (s *mockServer) Ready() <-chan struct{} {
+	return s.ready
+}
+
+func (s *mockServer) Stop(ctx context.Context) error {
+	s.rec.add("stop " + s.name)
+	s.once.Do(func() { close(s.stop) })
+	return nil
+}
+
+func TestApp_DependencyOrder(t *testing.T) {
+	rec := &recorder{}
+	db := newMockServer("db", rec)
+	cache := newMockServer("cache", rec)
+	http := newMockServer("http", rec)
+
+	a := New(
+		Server(http, cache, db),
+		Depend(http, db, cache),
+		Depend(cache, db),
+	)
+	time.AfterFunc(100*time.Millisecond, func() { _ = a.Stop() })
+	if err := a.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []string{"start db", "start cache", "start http", "stop http", "stop cache", "stop db"}
+	if got := rec.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestApp_DependencyCycle(t *testing.T) {
+	rec := &recorder{}
+	s1 := newMockServer("s1", rec)
+	s2 := newMockServer("s2", rec)
+	s3 := newMockServer("s3", rec)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"self", []Option{Server(s1), Depend(s1, s1)}},
+		{"pair", []Option{Server(s1, s2), Depend(s1, s2), Depend(s2, s1)}},
+		{"triangle", []Option{Server(s1, s2, s3), Depend(s1, s2), Depend(s2, s3), Depend(s3, s1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := New(tt.opts...).Run(); !errors.Is(err, ErrDependencyCycle) {
+				t.Errorf("Run() error = %v, want %v", err, ErrDependencyCycle)
+			}
+		})
+	}
+	if got := rec.list(); len(got) != 0 {
+		t.Errorf("servers were started despite the cycle: %v", got)
+	}
+}
+
+func TestApp_UnregisteredDependency(t *testing.T) {
+	rec := &recorder{}
+	s1 := newMockServer("s1", rec)
+	s2 := newMockServer("s2", rec)
+	if err := New(Server(s1), Depend(s1, s2)).Run(); err == nil {
+		t.Error("Run() error = nil, want error for unregistered dependency")
+	}
+}
+
+// blockingServer is a server.Server whose Stop ignores ctx until released.
+type blockingServer struct {
+	release chan struct{}
+	stopCtx chan context.Context
+}
+
+func (s *blockingServer) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (s *blockingServer) Stop(ctx context.Context) error {
+	s.stopCtx <- ctx
+	<-s.release
+	return nil
+}
+
+func TestApp_StopTimeout(t *testing.T) {
+	srv := &blockingServer{release: make(chan struct{}), stopCtx: make(chan context.Context, 1)}
+	defer close(srv.release)
+	a := New(Server(srv), StopTimeout(50*time.Millisecond))
+	time.AfterFunc(50*time.Millisecond, func() { _ = a.Stop() })
+
+	err := a.Run()
+	if !errors.Is(err, ErrStopTimeout) {
+		t.Fatalf("Run() error = %v, want %v", err, ErrStopTimeout)
+	}
+	if !strings.Contains(err.Error(), "#0(*app.blockingServer)") {
+		t.Errorf("Run() error = %v, want the server reported", err)
+	}
+	ctx := <-srv.stopCtx
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("Stop context has no deadline")
+	}
+	if _, ok := FromContext(ctx); !ok {
+		t.Error("Stop context does not carry AppInfo")
+	}
+}
+
+// failingServer is a server.Server whose Start fails immediately.
+type failingServer struct {
+	err error
+}
+
+func (s *failingServer) Start(ctx context.Context) error { return s.err }
+
+func (s *failingServer) Stop(ctx context.Context) error { return nil }
+
+func hook(rec *recorder, event string, err error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if _, ok := FromContext(ctx); !ok {
+			rec.add(event + " without AppInfo")
+		}
+		rec.add(event)
+		return err
+	}
+}
+
+func TestApp_Hooks(t *testing.T) {
+	rec := &recorder{}
+	srv := newMockServer("srv", rec)
+	var a *App
+	a = New(
+		Server(srv),
+		BeforeStart(hook(rec, "before start", nil)),
+		AfterStart(hook(rec, "after start", nil)),
+		AfterStart(func(ctx context.Context) error { return a.Stop() }),
+		BeforeStop(hook(rec, "before stop", nil)),
+		AfterStop(hook(rec, "after stop", nil)),
+	)
+	if err := a.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []string{"before start", "start srv", "after start", "before stop", "stop srv", "after stop"}
+	if got := rec.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestApp_BeforeStartError(t *testing.T) {
+	rec := &recorder{}
+	want := errors.New("migration failed")
+	a := New(
+		Server(newMockServer("srv", rec)),
+		BeforeStart(hook(rec, "before start", want)),
+		AfterStop(hook(rec, "after stop", nil)),
+	)
+	if err := a.Run(); !errors.Is(err, want) {
+		t.Fatalf("Run() error = %v, want %v", err, want)
+	}
+	if got := rec.list(); !reflect.DeepEqual(got, []string{"before start", "after stop"}) {
+		t.Errorf("events = %v, servers must not start", got)
+	}
+}
+
+func TestApp_AfterStopOnServerError(t *testing.T) {
+	rec := &recorder{}
+	want := errors.New("listen failed")
+	a := New(
+		Server(&failingServer{err: want}),
+		AfterStop(hook(rec, "after stop", nil)),
+	)
+	if err := a.Run(); !errors.Is(err, want) {
+		t.Fatalf("Run() error = %v, want %v", err, want)
+	}
+	if got := rec.list(); !reflect.DeepEqual(got, []string{"after stop"}) {
+		t.Errorf("events = %v, want AfterStop hook to run", got)
+	}
+}
+
+// slowServer is a server.Readier that never becomes ready.
+type slowServer struct {
+	blockingServer
+}
+
+func (s *slowServer) Ready() <-chan struct{} { return nil }
+
+func TestApp_ReadyTimeout(t *testing.T) {
+	rec := &recorder{}
+	slow := &slowServer{blockingServer{release: make(chan struct{}), stopCtx: make(chan context.Context, 1)}}
+	close(slow.release)
+	next := newMockServer("next", rec)
+	a := New(
+		Server(slow, next),
+		Depend(next, slow),
+		ReadyTimeout(50*time.Millisecond),
+	)
+	err := a.Run()
+	if !errors.Is(err, ErrNotReady) {
+		t.Fatalf("Run() error = %v, want %v", err, ErrNotReady)
+	}
+	if !strings.Contains(err.Error(), "#0(*app.slowServer)") {
+		t.Errorf("Run() error = %v, want the server reported", err)
+	}
+	if got := rec.list(); len(got) != 0 {
+		t.Errorf("events = %v, the next phase must not start", got)
+	}
+}
+
+// flakyServer is a server.Server whose Start fails the first failures times.
+type flakyServer struct {
+	mu       sync.Mutex
+	starts   int
+	failures int
+}
+
+func (s *flakyServer) Start(ctx context.Context) error {
+	s.mu.Lock()
+	s.starts++
+	n := s.starts
+	s.mu.Unlock()
+	if s.failures < 0 || n <= s.failures {
+		return errors.New("crashed")
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (s *flakyServer) Stop(ctx context.Context) error { return nil }
+
+func TestApp_Supervise(t *testing.T) {
+	srv := &flakyServer{failures: 2}
+	var events []RestartEvent
+	var a *App
+	a = New(
+		Server(srv),
+		Supervise(srv, RestartPolicy{Mode: RestartOnFailure, MinBackoff: time.Millisecond, MaxRestarts: 3}),
+		OnRestart(func(e RestartEvent) {
+			events = append(events, e)
+			if len(events) == 2 {
+				time.AfterFunc(50*time.Millisecond, func() { _ = a.Stop() })
+			}
+		}),
+	)
+	if err := a.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %+v, want 2 restarts", events)
+	}
+	if events[1].Restarts != 2 || events[1].Backoff != 2*time.Millisecond || events[1].Err == nil {
+		t.Errorf("events[1] = %+v, want the second restart with doubled backoff", events[1])
+	}
+}
+
+func TestApp_SuperviseExhausted(t *testing.T) {
+	srv := &flakyServer{failures: -1}
+	var exhausted bool
+	a := New(
+		Server(srv),
+		Supervise(srv, RestartPolicy{Mode: RestartAlways, MinBackoff: time.Millisecond, MaxRestarts: 3, Window: time.Minute}),
+		OnRestart(func(e RestartEvent) { exhausted = e.Exhausted }),
+	)
+	err := a.Run()
+	if !errors.Is(err, ErrRestartExhausted) {
+		t.Fatalf("Run() error = %v, want %v", err, ErrRestartExhausted)
+	}
+	if !exhausted {
+		t.Error("the last event does not report the exhausted budget")
+	}
+	if srv.starts != 4 {
+		t.Errorf("starts = %d, want 4", srv.starts)
+	}
+}
+
+// oneShotServer is a server.Server that cannot be restarted.
+type oneShotServer struct{ flakyServer }
+
+func (s *oneShotServer) Restartable() bool { return false }
+
+func TestApp_SuperviseNotRestartable(t *testing.T) {
+	srv := &oneShotServer{}
+	a := New(Server(srv), Supervise(srv, RestartPolicy{Mode: RestartOnFailure}))
+	if err := a.Run(); err == nil || !strings.Contains(err.Error(), "cannot be restarted") {
+		t.Errorf("Run() error = %v, want the server rejected", err)
+	}
+}
+
+// configServer is a server.Server reading the configuration from its start context.
+type configServer struct {
+	name chan string
+}
+
+func (s *configServer) Start(ctx context.Context) error {
+	c, ok := config.FromContext[struct{ Name string }](ctx)
+	if !ok {
+		return errors.New("no config in context")
+	}
+	s.name <- c.Get().Name
+	return nil
+}
+
+func (s *configServer) Stop(ctx context.Context) error { return nil }
+
+func TestApp_Config(t *testing.T) {
+	c, err := config.Load(struct{ Name string }{Name: "svc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &configServer{name: make(chan string, 1)}
+	a := New(Server(srv), Config(c))
+	time.AfterFunc(50*time.Millisecond, func() { _ = a.Stop() })
+	if err := a.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := <-srv.name; got != "svc" {
+		t.Errorf("config name = %q, want svc", got)
+	}
+}
+
+// logRecorder is a log.Logger recording the messages and their key values.
+type logRecorder struct {
+	mu      sync.Mutex
+	records []map[string]interface{}
+}
+
+func (l *logRecorder) Log(_ context.Context, _ log.Level, msg string, keyValues ...interface{}) {
+	r := map[string]interface{}{"msg": msg}
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		r[keyValues[i].(string)] = keyValues[i+1]
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, r)
+}
+
+func (l *logRecorder) Close() error { return nil }
+
+func TestApp_Logger(t *testing.T) {
+	rec := &recorder{}
+	logs := &logRecorder{}
+	a := New(ID("id-1"), Name("svc"), Version("v1.0.0"),
+		Logger(log.NewAdapter(logs)),
+		Server(newMockServer("a", rec)))
+	time.AfterFunc(50*time.Millisecond, func() { _ = a.Stop() })
+	if err := a.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var msgs []string
+	for _, r := range logs.records {
+		if r["app.id"] != "id-1" || r["app.name"] != "svc" || r["app.version"] != "v1.0.0" {
+			t.Errorf("record %v misses the app fields", r)
+		}
+		msgs = append(msgs, r["msg"].(string))
+	}
+	want := []string{"app starting", "app ready", "app stopping", "server stopped", "app stopped"}
+	if !reflect.DeepEqual(msgs, want) {
+		t.Errorf("messages = %v, want %v", msgs, want)
+	}
+}
+
+// recordingRegistrar is a registry.Registrar recording the calls.
+type recordingRegistrar struct {
+	rec *recorder
+	reg *registry.Memory
+}
+
+func (r *recordingRegistrar) Register(ctx context.Context, ins *registry.ServiceInstance) error {
+	r.rec.add("register " + ins.ID)
+	return r.reg.Register(ctx, ins)
+}
+
+func (r *recordingRegistrar) Deregister(ctx context.Context, ins *registry.ServiceInstance) error {
+	r.rec.add("deregister " + ins.ID)
+	return r.reg.Deregister(ctx, ins)
+}
+
+func TestApp_Registrar(t *testing.T) {
+	rec := &recorder{}
+	mem := registry.NewMemory()
+	u, _ := url.Parse("http://127.0.0.1:8000")
+	var a *App
+	a = New(ID("id-1"), Name("svc"),
+		Endpoint(u),
+		Registrar(&recordingRegistrar{rec: rec, reg: mem}),
+		Server(newMockServer("a", rec)),
+		AfterStart(func(ctx context.Context) error {
+			list, err := mem.GetService(ctx, "svc")
+			if err != nil || len(list) != 1 || list[0].Endpoints[0] != "http://127.0.0.1:8000" {
+				t.Errorf("GetService() = %v, %v, want the registered app", list, err)
+			}
+			return a.Stop()
+		}),
+		BeforeStop(func(context.Context) error {
+			rec.add("before stop")
+			return nil
+		}))
+	if err := a.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := []string{"start a", "register id-1", "deregister id-1", "before stop", "stop a"}
+	if got := rec.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if list, _ := mem.GetService(context.Background(), "svc"); len(list) != 0 {
+		t.Errorf("GetService() after Run = %v, want none", list)
+	}
+}
+
+// endpointServer is a mockServer implementing server.Endpointer.
+type endpointServer struct {
+	*mockServer
+	endpoint string
+}
+
+func (s *endpointServer) Endpoints() []*url.URL {
+	u, _ := url.Parse(s.endpoint)
+	return []*url.URL{u}
+}
+
+func TestApp_Endpoints(t *testing.T) {
+	rec := &recorder{}
+	a := New(Server(
+		&endpointServer{mockServer: newMockServer("a", rec), endpoint: "http://127.0.0.1:8000"},
+		newMockServer("b", rec),
+		&endpointServer{mockServer: newMockServer("c", rec), endpoint: "unix:///tmp/c.sock"},
+	))
+	want := []string{"http://127.0.0.1:8000", "unix:///tmp/c.sock"}
+	if got := a.Endpoints(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Endpoints() = %v, want %v", got, want)
+	}
+
+	u, _ := url.Parse("http://proxy:80")
+	a = New(Endpoint(u), Server(&endpointServer{mockServer: newMockServer("a", rec), endpoint: "http://127.0.0.1:8000"}))
+	if got := a.Endpoints(); !reflect.DeepEqual(got, []string{"http://proxy:80"}) {
+		t.Errorf("Endpoints() = %v, want the Endpoint option", got)
+	}
+}
+
+// stopFailingServer is a named mockServer whose Stop fails.
+type stopFailingServer struct {
+	*mockServer
+	err error
+}
+
+func (s *stopFailingServer) Name() string { return s.name }
+
+func (s *stopFailingServer) Stop(ctx context.Context) error {
+	_ = s.mockServer.Stop(ctx)
+	return s.err
+}
+
+func TestApp_RunError(t *testing.T) {
+	rec := &recorder{}
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	a := New(Server(
+		&stopFailingServer{mockServer: newMockServer("a", rec), err: errA},
+		&stopFailingServer{mockServer: newMockServer("b", rec), err: errB},
+		newMockServer("c", rec),
+	))
+	time.AfterFunc(50*time.Millisecond, func() { _ = a.Stop() })
+
+	err := a.Run()
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("Run() error = %v, want both stop errors", err)
+	}
+	var runErr *RunError
+	if !errors.As(err, &runErr) || len(runErr.Servers) != 2 || len(runErr.Errs) != 0 {
+		t.Fatalf("Run() error = %#v, want a RunError with two server errors", err)
+	}
+	got := map[string]error{}
+	for _, se := range runErr.Servers {
+		if se.Phase != PhaseStop {
+			t.Errorf("%s phase = %s, want %s", se.Name, se.Phase, PhaseStop)
+		}
+		got[se.Name] = se.Err
+	}
+	if want := map[string]error{"a": errA, "b": errB}; !reflect.DeepEqual(got, want) {
+		t.Errorf("server errors = %v, want %v", got, want)
+	}
+	var se *ServerError
+	if !errors.As(err, &se) || se.Index > 1 {
+		t.Errorf("errors.As(*ServerError) = %v", se)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+// Package apptest provides utilities for testing applications built on app.App:
+// a Harness running the App in-process with simulated signals, fake servers
+// and a Recorder asserting the order of the lifecycle events.
+package apptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-inspire/pkg/app"
+)
+
+// ErrTimeout is returned by the Harness when the App misses a deadline.
+var ErrTimeout = errors.New("apptest: timeout")
+
+// Lifecycle hook events recorded by the Harness.
+const (
+	BeforeStart = "before start"
+	AfterStart  = "after start"
+	BeforeStop  = "before stop"
+	AfterStop   = "after stop"
+)
+
+// Harness runs an App in-process. Signals are never read from the process,
+// they are injected with Signal.
+type Harness struct {
+	t        testing.TB
+	app      *app.App
+	rec      *Recorder
+	notifier *notifier
+	ready    chan struct{}
+	done     chan struct{}
+	err      error // the Run error, set before done is closed
+}
+
+// Start creates the App with opts and runs it in a new goroutine. The Harness
+// records the lifecycle hooks to rec, which may be nil: BeforeStart and
+// BeforeStop before the hooks of opts, AfterStart and AfterStop after them.
+// The App is stopped at the end of the test if it is still running.
+func Start(t testing.TB, rec *Recorder, opts ...app.Option) *Harness {
+	t.Helper()
+	if rec == nil {
+		rec = &Recorder{}
+	}
+	h := &Harness{
+		t:        t,
+		rec:      rec,
+		notifier: &notifier{subs: make(map[chan<- os.Signal][]os.Signal)},
+		ready:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	record := func(event string) func(context.Context) error {
+		return func(context.Context) error {
+			h.rec.Add(event)
+			return nil
+		}
+	}
+	opts = append(append([]app.Option{
+		app.BeforeStart(record(BeforeStart)),
+		app.BeforeStop(record(BeforeStop)),
+	}, opts...),
+		app.AfterStart(record(AfterStart)),
+		app.AfterStop(record(AfterStop)),
+		// Run subscribes to the signals before the BeforeStart hooks,
+		// so a Signal sent once WaitReady returned is never dropped
+		app.AfterStart(func(context.Context) error {
+			close(h.ready)
+			return nil
+		}),
+		app.Notifier(h.notifier),
+	)
+	h.app = app.New(opts...)
+	go func() {
+		defer close(h.done)
+		h.err = h.app.Run()
+	}()
+	t.Cleanup(func() {
+		_ = h.app.Stop()
+		<-h.done
+	})
+	return h
+}
+
+// App returns the App under test.
+func (h *Harness) App() *app.App {
+	return h.app
+}
+
+// Recorder returns the Recorder of the lifecycle events.
+func (h *Harness) Recorder() *Recorder {
+	return h.rec
+}
+
+// WaitReady waits until every server is ready and the AfterStart hooks ran.
+// It returns the Run error if the App exits first, and ErrTimeout if it is
+// not ready within timeout.
+func (h *Harness) WaitReady(timeout time.Duration) error {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-h.ready:
+		return nil
+	case <-h.done:
+		if h.err == nil {
+			return errors.New("apptest: app exited before it was ready")
+		}
+		return h.err
+	case <-t.C:
+		return fmt.Errorf("%w: app not ready after %s", ErrTimeout, timeout)
+	}
+}
+
+// Signal delivers sig to the App as if the process received it. It blocks
+// until the signal is queued to Run, and returns false if Run does not
+// listen to sig or has returned.
+func (h *Harness) Signal(sig os.Signal) bool {
+	return h.notifier.send(sig, h.done)
+}
+
+// Stop stops the App, as App.Stop.
+func (h *Harness) Stop() {
+	_ = h.app.Stop()
+}
+
+// Wait waits for Run to return and returns its error,
+// or ErrTimeout if Run does not return within timeout.
+func (h *Harness) Wait(timeout time.Duration) error {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-h.done:
+		return h.err
+	case <-t.C:
+		return fmt.Errorf("%w: Run did not return within %s", ErrTimeout, timeout)
+	}
+}
+
+// RequireExit fails the test unless Run returns within timeout, and returns the Run error.
+func (h *Harness) RequireExit(timeout time.Duration) error {
+	h.t.Helper()
+	err := h.Wait(timeout)
+	if errors.Is(err, ErrTimeout) {
+		h.t.Fatal(err)
+	}
+	return err
+}
+
+// notifier is the app.SignalNotifier of the simulated signals.
+type notifier struct {
+	mu   sync.Mutex
+	subs map[chan<- os.Signal][]os.Signal
+}
+
+func (n *notifier) Notify(c chan<- os.Signal, sigs ...os.Signal) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subs[c] = append(n.subs[c], sigs...)
+}
+
+func (n *notifier) Stop(c chan<- os.Signal) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.subs, c)
+}
+
+// send delivers sig to the channels notified of it, until done is closed.
+func (n *notifier) send(sig os.Signal, done <-chan struct{}) bool {
+	n.mu.Lock()
+	var targets []chan<- os.Signal
+	for c, sigs := range n.subs {
+		for _, s := range sigs {
+			if s == sig {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	n.mu.Unlock()
+
+	delivered := false
+	for _, c := range targets {
+		select {
+		case c <- sig:
+			delivered = true
+		case <-done:
+			return delivered
+		}
+	}
+	return delivered
+}
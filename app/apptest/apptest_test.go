@@ -0,0 +1,108 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package apptest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-inspire/pkg/app"
+)
+
+func TestHarness_Signal(t *testing.T) {
+	rec := &Recorder{}
+	db, api := NewServer("db", rec), NewServer("api", rec)
+	api.ReadyDelay = 10 * time.Millisecond
+	h := Start(t, rec, app.Server(db, api), app.Depend(api, db))
+	if err := h.WaitReady(time.Second); err != nil {
+		t.Fatalf("WaitReady() error = %v", err)
+	}
+	if !h.Signal(syscall.SIGTERM) {
+		t.Fatal("SIGTERM was not delivered")
+	}
+	if err := h.RequireExit(time.Second); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	rec.AssertEvents(t, BeforeStart, "start db", "start api", AfterStart, BeforeStop, "stop api", "stop db", AfterStop)
+}
+
+func TestHarness_SignalAfterReady(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		h := Start(t, nil)
+		if err := h.WaitReady(time.Second); err != nil {
+			t.Fatalf("WaitReady() error = %v", err)
+		}
+		if !h.Signal(syscall.SIGTERM) {
+			t.Fatalf("run %d: SIGTERM sent right after WaitReady was dropped", i)
+		}
+		if err := h.RequireExit(time.Second); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+}
+
+func TestHarness_ForcedStop(t *testing.T) {
+	rec := &Recorder{}
+	srv := NewServer("slow", rec)
+	srv.StopDelay = time.Minute
+	h := Start(t, rec, app.Server(srv))
+	if err := h.WaitReady(time.Second); err != nil {
+		t.Fatalf("WaitReady() error = %v", err)
+	}
+	h.Signal(syscall.SIGINT)
+	if err := h.Wait(20 * time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Wait() error = %v, want %v", err, ErrTimeout)
+	}
+	h.Signal(syscall.SIGINT)
+	if err := h.RequireExit(time.Second); !errors.Is(err, app.ErrForcedStop) {
+		t.Errorf("Run() error = %v, want %v", err, app.ErrForcedStop)
+	}
+	rec.AssertOrder(t, "start slow", BeforeStop, "stop slow", AfterStop)
+}
+
+func TestHarness_StartError(t *testing.T) {
+	rec := &Recorder{}
+	srv := NewServer("broken", rec)
+	srv.StartErr = errors.New("listen failed")
+	h := Start(t, rec, app.Server(srv))
+	if err := h.WaitReady(time.Second); !errors.Is(err, srv.StartErr) {
+		t.Errorf("WaitReady() error = %v, want %v", err, srv.StartErr)
+	}
+	var se *app.ServerError
+	if err := h.RequireExit(time.Second); !errors.As(err, &se) || se.Name != "broken" || se.Phase != app.PhaseStart {
+		t.Errorf("Run() error = %v, want the start error of broken", err)
+	}
+	if h.Signal(syscall.SIGTERM) {
+		t.Error("Signal() delivered after Run returned")
+	}
+}
+
+func TestHarness_SignalHandler(t *testing.T) {
+	got := make(chan os.Signal, 1)
+	h := Start(t, nil, app.HandleSignal(syscall.SIGHUP, func(ctx context.Context, sig os.Signal) {
+		got <- sig
+	}))
+	if err := h.WaitReady(time.Second); err != nil {
+		t.Fatalf("WaitReady() error = %v", err)
+	}
+	h.Signal(syscall.SIGHUP)
+	select {
+	case sig := <-got:
+		if sig != syscall.SIGHUP {
+			t.Errorf("handled %v, want SIGHUP", sig)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SIGHUP was not handled")
+	}
+	if err := h.Wait(20 * time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Wait() error = %v, want the app still running", err)
+	}
+}
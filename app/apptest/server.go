@@ -0,0 +1,145 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package apptest
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-inspire/pkg/app/server"
+)
+
+// Recorder records lifecycle events, it is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+// Add records an event.
+func (r *Recorder) Add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// Events returns the recorded events.
+func (r *Recorder) Events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// AssertOrder fails the test unless the events were recorded in the given
+// order, other events may be recorded in between.
+func (r *Recorder) AssertOrder(t testing.TB, events ...string) {
+	t.Helper()
+	got := r.Events()
+	k := 0
+	for _, e := range got {
+		if k < len(events) && e == events[k] {
+			k++
+		}
+	}
+	if k < len(events) {
+		t.Errorf("events = %v, want %v in order, missing %q", got, events, events[k])
+	}
+}
+
+// AssertEvents fails the test unless exactly the events were recorded.
+func (r *Recorder) AssertEvents(t testing.TB, events ...string) {
+	t.Helper()
+	if got := r.Events(); !slices.Equal(got, events) {
+		t.Errorf("events = %v, want %v", got, events)
+	}
+}
+
+var (
+	_ server.Server  = (*Server)(nil)
+	_ server.Readier = (*Server)(nil)
+	_ server.Named   = (*Server)(nil)
+)
+
+// Server is a fake server.Server recording "start <name>" and "stop <name>"
+// to its Recorder. Start blocks until the server is stopped.
+type Server struct {
+	name string
+	rec  *Recorder
+
+	// StartErr is returned by Start immediately if set.
+	StartErr error
+	// StopErr is returned by Stop.
+	StopErr error
+	// StopDelay delays Stop, or until the Stop context is done.
+	StopDelay time.Duration
+	// ReadyDelay delays the readiness after Start is called.
+	ReadyDelay time.Duration
+
+	ready     chan struct{}
+	stop      chan struct{}
+	readyOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewServer creates a fake server recording to rec.
+func NewServer(name string, rec *Recorder) *Server {
+	return &Server{
+		name:  name,
+		rec:   rec,
+		ready: make(chan struct{}),
+		stop:  make(chan struct{}),
+	}
+}
+
+// Name implements server.Named.
+func (s *Server) Name() string {
+	return s.name
+}
+
+// Start records the start and blocks until the server is stopped.
+func (s *Server) Start(ctx context.Context) error {
+	s.rec.Add("start " + s.name)
+	if s.StartErr != nil {
+		return fmt.Errorf("%s: %w", s.name, s.StartErr)
+	}
+	if s.ReadyDelay > 0 {
+		time.AfterFunc(s.ReadyDelay, s.markReady)
+	} else {
+		s.markReady()
+	}
+	<-s.stop
+	return nil
+}
+
+// Stop records the stop and unblocks Start after StopDelay,
+// or once the Stop context is done.
+func (s *Server) Stop(ctx context.Context) error {
+	s.rec.Add("stop " + s.name)
+	defer s.stopOnce.Do(func() { close(s.stop) })
+	if s.StopDelay > 0 {
+		t := time.NewTimer(s.StopDelay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return s.StopErr
+}
+
+// Ready implements server.Readier.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+func (s *Server) markReady() {
+	s.readyOnce.Do(func() { close(s.ready) })
+}
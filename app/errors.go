@@ -0,0 +1,102 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Phase is the lifecycle phase a server failed in.
+type Phase string
+
+const (
+	// PhaseStart is the start of a server, until Start returns.
+	PhaseStart Phase = "start"
+	// PhaseStop is the stop of a server, until Stop returns.
+	PhaseStop Phase = "stop"
+)
+
+// ServerError reports the failure of a server.
+type ServerError struct {
+	// Index is the index of the server in the Server option.
+	Index int
+	// Name is the server.Named name of the server, or its index and type.
+	Name string
+	// Phase is the lifecycle phase the server failed in.
+	Phase Phase
+	// Err is the failure.
+	Err error
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Name, e.Phase, e.Err)
+}
+
+func (e *ServerError) Unwrap() error { return e.Err }
+
+// RunError is returned by Run, it reports every failure of the run.
+// errors.Is and errors.As match the errors of all failures.
+type RunError struct {
+	// Servers lists the failures of the servers in the order they occurred.
+	Servers []*ServerError
+	// Errs lists the failures not attributed to a server, such as hook errors.
+	Errs []error
+}
+
+func (e *RunError) Error() string {
+	var b strings.Builder
+	for i, err := range e.Unwrap() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+func (e *RunError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Servers)+len(e.Errs))
+	for _, err := range e.Servers {
+		errs = append(errs, err)
+	}
+	return append(errs, e.Errs...)
+}
+
+// errorList collects the failures of a run, it is safe for concurrent use.
+type errorList struct {
+	mu  sync.Mutex
+	err RunError
+}
+
+// add adds err to the list, nil is ignored.
+func (l *errorList) add(err error) {
+	if err == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if se, ok := err.(*ServerError); ok {
+		l.err.Servers = append(l.err.Servers, se)
+	} else {
+		l.err.Errs = append(l.err.Errs, err)
+	}
+}
+
+// result returns a RunError with the failures so far, or nil if there are none.
+func (l *errorList) result() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.err.Servers) == 0 && len(l.err.Errs) == 0 {
+		return nil
+	}
+	return &RunError{
+		Servers: append([]*ServerError(nil), l.err.Servers...),
+		Errs:    append([]error(nil), l.err.Errs...),
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-inspire/pkg/app/server"
+	"reflect"
+)
+
+// ErrDependencyCycle is returned when the declared server dependencies contain a cycle.
+var ErrDependencyCycle = errors.New("app: server dependency cycle")
+
+// dependency declares that srv depends on deps.
+type dependency struct {
+	srv  server.Server
+	deps []server.Server
+}
+
+// indexOf returns the position of srv in servers, or -1 if it is not registered.
+func indexOf(servers []server.Server, srv server.Server) int {
+	if srv == nil || !reflect.TypeOf(srv).Comparable() {
+		return -1
+	}
+	for i, s := range servers {
+		if s != nil && reflect.TypeOf(s).Comparable() && s == srv {
+			return i
+		}
+	}
+	return -1
+}
+
+// phases groups the indexes of servers into start phases by their declared
+// dependencies. Servers in the same phase have no dependencies on each other
+// and may be started concurrently; every phase only depends on the phases before it.
+func phases(servers []server.Server, deps []dependency) ([][]int, error) {
+	n := len(servers)
+	edges := make([][]int, n) // edges[i]: servers depending on i
+	indegree := make([]int, n)
+	for _, d := range deps {
+		i := indexOf(servers, d.srv)
+		if i < 0 {
+			return nil, fmt.Errorf("app: dependent server %T is not registered", d.srv)
+		}
+		for _, dep := range d.deps {
+			j := indexOf(servers, dep)
+			if j < 0 {
+				return nil, fmt.Errorf("app: dependency %T of server %T is not registered", dep, d.srv)
+			}
+			if i == j {
+				return nil, fmt.Errorf("%w: server %T depends on itself", ErrDependencyCycle, d.srv)
+			}
+			edges[j] = append(edges[j], i)
+			indegree[i]++
+		}
+	}
+
+	var result [][]int
+	var current []int
+	for i := 0; i < n; i++ {
+		if indegree[i] == 0 {
+			current = append(current, i)
+		}
+	}
+	visited := 0
+	for len(current) > 0 {
+		var next []int
+		for _, i := range current {
+			visited++
+			for _, j := range edges[i] {
+				indegree[j]--
+				if indegree[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		result = append(result, current)
+		current = next
+	}
+	if visited != n {
+		var cycle []string
+		for i := 0; i < n; i++ {
+			if indegree[i] > 0 {
+				cycle = append(cycle, server.Name(i, servers[i]))
+			}
+		}
+		return nil, fmt.Errorf("%w among servers %v", ErrDependencyCycle, cycle)
+	}
+	return result, nil
+}
@@ -0,0 +1,227 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+// Package health provides a server.Server serving the liveness, readiness
+// and application info endpoints of an app.App.
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/go-inspire/pkg/app"
+	"github.com/go-inspire/pkg/app/server"
+	"github.com/go-inspire/pkg/app/server/adapter"
+	"github.com/go-inspire/pkg/encoding"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+var _ server.Server = (*Server)(nil)
+var _ server.Readier = (*Server)(nil)
+var _ server.Endpointer = (*Server)(nil)
+
+// Option is a health server option.
+type Option func(s *Server)
+
+// Address with the listen address, ":8081" by default.
+func Address(addr string) Option {
+	return func(s *Server) { s.addr = addr }
+}
+
+// Check with an additional named readiness check, such as a database ping.
+func Check(name string, fn func(context.Context) error) Option {
+	return func(s *Server) { s.checks = append(s.checks, check{name: name, fn: fn}) }
+}
+
+// Paths with the liveness, readiness and info endpoint paths,
+// "/healthz", "/readyz" and "/info" by default.
+func Paths(live, ready, info string) Option {
+	return func(s *Server) { s.live, s.readyz, s.info = live, ready, info }
+}
+
+// check is a named readiness check.
+type check struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// Status is the response of the readiness endpoint.
+type Status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Info is the response of the info endpoint.
+type Info struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Endpoints []string          `json:"endpoints,omitempty"`
+}
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+	statusStopping    = "stopping"
+)
+
+// Server serves the health endpoints of the application it is registered with.
+// The readiness endpoint fails until every server.Readier is ready, while any
+// server.HealthChecker or Check fails, and as soon as the application stops.
+type Server struct {
+	addr   string
+	live   string
+	readyz string
+	info   string
+	checks []check
+
+	srv      *adapter.HTTP
+	stopping atomic.Bool
+
+	mu      sync.RWMutex
+	app     app.AppInfo
+	servers []server.Server
+}
+
+// New creates a health server.
+func New(opts ...Option) *Server {
+	s := &Server{
+		addr:   ":8081",
+		live:   "/healthz",
+		readyz: "/readyz",
+		info:   "/info",
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.live, s.serveLive)
+	mux.HandleFunc(s.readyz, s.serveReady)
+	mux.HandleFunc(s.info, s.serveInfo)
+	s.srv = adapter.NewHTTP(&http.Server{Addr: s.addr, Handler: mux})
+	return s
+}
+
+// Start listens on the address and serves the health endpoints until stopped.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if info, ok := app.FromContext(ctx); ok {
+		s.app = info
+		if a, ok := info.(interface{ Servers() []server.Server }); ok {
+			s.servers = a.Servers()
+		}
+	}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done() // the application is stopping
+		s.stopping.Store(true)
+	}()
+	return s.srv.Start(ctx)
+}
+
+// Stop marks the application not ready and gracefully shuts down the server.
+func (s *Server) Stop(ctx context.Context) error {
+	s.stopping.Store(true)
+	return s.srv.Stop(ctx)
+}
+
+// Ready implements server.Readier, it is closed once the server is listening.
+func (s *Server) Ready() <-chan struct{} {
+	return s.srv.Ready()
+}
+
+// Addr returns the listen address, or nil if the server is not started.
+func (s *Server) Addr() net.Addr {
+	return s.srv.Addr()
+}
+
+// Endpoints implements server.Endpointer, it returns nil if the server is not started.
+func (s *Server) Endpoints() []*url.URL {
+	return s.srv.Endpoints()
+}
+
+// Readiness runs all readiness checks and returns the aggregated status.
+func (s *Server) Readiness(ctx context.Context) Status {
+	if s.stopping.Load() {
+		return Status{Status: statusStopping}
+	}
+	s.mu.RLock()
+	servers := s.servers
+	s.mu.RUnlock()
+
+	status := Status{Status: statusOK, Checks: make(map[string]string)}
+	report := func(name string, err error) {
+		if err != nil {
+			status.Status = statusUnavailable
+			status.Checks[name] = err.Error()
+		} else {
+			status.Checks[name] = statusOK
+		}
+	}
+	for i, srv := range servers {
+		if srv == server.Server(s) {
+			continue
+		}
+		name := server.Name(i, srv)
+		if r, ok := srv.(server.Readier); ok {
+			select {
+			case <-r.Ready():
+			default:
+				report(name, errors.New("not ready"))
+				continue
+			}
+		}
+		if hc, ok := srv.(server.HealthChecker); ok {
+			report(name, hc.HealthCheck(ctx))
+		}
+	}
+	for _, c := range s.checks {
+		report(c.name, c.fn(ctx))
+	}
+	return status
+}
+
+func (s *Server) serveLive(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Status{Status: statusOK})
+}
+
+func (s *Server) serveReady(w http.ResponseWriter, r *http.Request) {
+	status := s.Readiness(r.Context())
+	code := http.StatusOK
+	if status.Status != statusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+func (s *Server) serveInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	info := s.app
+	s.mu.RUnlock()
+	if info == nil {
+		http.Error(w, "app info unavailable", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, Info{
+		ID:        info.ID(),
+		Name:      info.Name(),
+		Version:   info.Version(),
+		Metadata:  info.Metadata(),
+		Endpoints: info.Endpoints(),
+	})
+}
+
+// writeJSON writes v as the JSON response body with the status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = encoding.NewEncoderFunc(w)(v)
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-inspire/pkg/app"
+)
+
+// checkedServer is a server.HealthChecker reporting a configurable error.
+type checkedServer struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (s *checkedServer) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (s *checkedServer) Stop(ctx context.Context) error { return nil }
+
+func (s *checkedServer) HealthCheck(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *checkedServer) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func get(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", url, err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s decode error = %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	hs := New(Address("127.0.0.1:0"))
+	checked := &checkedServer{}
+	a := app.New(
+		app.ID("id-1"),
+		app.Name("health-test"),
+		app.Version("v1.0.0"),
+		app.Metadata(map[string]string{"zone": "a"}),
+		app.Server(hs, checked),
+	)
+	done := make(chan error, 1)
+	go func() { done <- a.Run() }()
+	<-hs.Ready()
+	base := "http://" + hs.Addr().String()
+
+	if code := get(t, base+"/healthz", nil); code != http.StatusOK {
+		t.Errorf("liveness code = %d, want %d", code, http.StatusOK)
+	}
+
+	var status Status
+	if code := get(t, base+"/readyz", &status); code != http.StatusOK || status.Status != statusOK {
+		t.Errorf("readiness = %d %+v, want ok", code, status)
+	}
+
+	checked.fail(errors.New("db down"))
+	status = Status{}
+	if code := get(t, base+"/readyz", &status); code != http.StatusServiceUnavailable || status.Checks["#1(*health.checkedServer)"] != "db down" {
+		t.Errorf("readiness = %d %+v, want the failing check reported", code, status)
+	}
+	checked.fail(nil)
+
+	var info Info
+	get(t, base+"/info", &info)
+	if info.ID != "id-1" || info.Name != "health-test" || info.Version != "v1.0.0" || info.Metadata["zone"] != "a" {
+		t.Errorf("info = %+v", info)
+	}
+	if len(info.Endpoints) != 1 || info.Endpoints[0] != base {
+		t.Errorf("info endpoints = %v, want [%s]", info.Endpoints, base)
+	}
+
+	_ = a.Stop()
+	deadline := time.Now().Add(time.Second)
+	for !hs.stopping.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := hs.Readiness(context.Background()); got.Status != statusStopping {
+		t.Errorf("readiness after stop = %+v, want %s", got, statusStopping)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+//go:build !windows
+
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package leader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var _ Locker = (*FileLocker)(nil)
+
+// FileLocker is a Locker holding an exclusive flock(2) on a file, for the
+// replicas of a single host. The lease is lost when the lock file is removed
+// or replaced, and released when the process exits.
+type FileLocker struct {
+	path     string
+	interval time.Duration
+}
+
+// NewFileLocker creates a FileLocker of the file at path, which is created if
+// missing. The lock and the lock file are polled every interval, 100ms if zero.
+func NewFileLocker(path string, interval time.Duration) *FileLocker {
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	return &FileLocker{path: path, interval: interval}
+}
+
+// Lock implements Locker.
+func (l *FileLocker) Lock(ctx context.Context) (Lease, error) {
+	for {
+		f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil && sameFile(f, l.path) {
+			return newFileLease(f, l.path, l.interval), nil
+		}
+		f.Close()
+		if err != nil && !errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, err
+		}
+		if !sleep(ctx, l.interval) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// fileLease is the Lease of a FileLocker.
+type fileLease struct {
+	f      *os.File
+	lost   chan struct{}
+	cancel chan struct{}
+	once   sync.Once
+	done   chan struct{}
+}
+
+func newFileLease(f *os.File, path string, interval time.Duration) *fileLease {
+	l := &fileLease{
+		f:      f,
+		lost:   make(chan struct{}),
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(l.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-l.cancel:
+				return
+			case <-t.C:
+				if !sameFile(f, path) {
+					close(l.lost)
+					return
+				}
+			}
+		}
+	}()
+	return l
+}
+
+func (l *fileLease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *fileLease) Unlock() error {
+	var err error
+	l.once.Do(func() {
+		close(l.cancel)
+		<-l.done
+		err = errors.Join(syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN), l.f.Close())
+	})
+	return err
+}
+
+// sameFile reports whether f is still the file at path.
+func sameFile(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fi, pi)
+}
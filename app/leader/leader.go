@@ -0,0 +1,236 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+// Package leader provides a server.Server wrapper running the inner server
+// only on the replica holding a lease, with a pluggable Locker and a file
+// lock implementation.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-inspire/pkg/app/server"
+)
+
+// ErrLeaseLost is passed to the inner server's Stop context cause when the lease is lost.
+var ErrLeaseLost = errors.New("leader: lease lost")
+
+// Locker acquires leases, one replica at a time holds the lease.
+type Locker interface {
+	// Lock blocks until the lease is acquired or ctx is done.
+	Lock(ctx context.Context) (Lease, error)
+}
+
+// Lease is a lease acquired from a Locker.
+type Lease interface {
+	// Lost returns a channel closed when the lease is lost.
+	Lost() <-chan struct{}
+	// Unlock releases the lease.
+	Unlock() error
+}
+
+// Option is a Server option.
+type Option func(s *Server)
+
+// RetryInterval with the delay before acquiring the lease again after Lock
+// failed, 1 second by default.
+func RetryInterval(d time.Duration) Option {
+	return func(s *Server) { s.retry = d }
+}
+
+// StopTimeout with the deadline of the inner server's Stop when the lease is
+// lost, 30 seconds by default. The lease is acquired again only once the
+// inner server's Start returned, even if Stop missed the deadline.
+func StopTimeout(d time.Duration) Option {
+	return func(s *Server) { s.stopTimeout = d }
+}
+
+// OnChange with a func notified when the replica becomes or stops being the leader.
+func OnChange(fn func(leader bool)) Option {
+	return func(s *Server) { s.onChange = append(s.onChange, fn) }
+}
+
+var _ server.Server = (*Server)(nil)
+
+// Server runs the inner server only while holding the lease of its Locker.
+// It starts the inner server once the lease is acquired, stops it when the
+// lease is lost and then waits to acquire the lease again, so the inner
+// server must support being started again after Stop. The Server itself can
+// be started again after Start returned, e.g. under an app restart policy.
+type Server struct {
+	inner       server.Server
+	locker      Locker
+	retry       time.Duration
+	stopTimeout time.Duration
+	onChange    []func(bool)
+
+	leader atomic.Bool
+	mu     sync.Mutex
+	run    *run // the current or next run of Start
+}
+
+// run is the state of one Start, so the Server can be started again.
+type run struct {
+	stopOnce sync.Once
+	stopping chan struct{}
+	stopCtx  context.Context // the Stop context, set before stopping is closed
+	stopErr  error           // the inner server's Stop error, set before exited is closed
+	exited   chan struct{}
+}
+
+// New creates a Server running inner while holding a lease of locker.
+// It panics if inner implements server.Restartable and cannot be restarted.
+func New(inner server.Server, locker Locker, opts ...Option) *Server {
+	if r, ok := inner.(server.Restartable); ok && !r.Restartable() {
+		panic(fmt.Sprintf("leader: inner server %T cannot be restarted", inner))
+	}
+	s := &Server{
+		inner:       inner,
+		locker:      locker,
+		retry:       time.Second,
+		stopTimeout: 30 * time.Second,
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// current returns the run of the running Start. If the last Start returned,
+// Start begins a new run, while Stop returns that of the last Start. A Stop
+// before the first Start applies to it.
+func (s *Server) current(start bool) *run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.run != nil {
+		select {
+		case <-s.run.exited:
+			if !start {
+				return s.run
+			}
+		default:
+			return s.run
+		}
+	}
+	s.run = &run{stopping: make(chan struct{}), exited: make(chan struct{})}
+	return s.run
+}
+
+// IsLeader reports whether the inner server runs on this replica.
+func (s *Server) IsLeader() bool {
+	return s.leader.Load()
+}
+
+// Start acquires the lease and runs the inner server until it is stopped.
+// It returns the error of the inner server's Start, if any.
+func (s *Server) Start(ctx context.Context) error {
+	r := s.current(true)
+	defer close(r.exited)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-r.stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for ctx.Err() == nil {
+		lease, err := s.locker.Lock(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !sleep(ctx, s.retry) {
+				return nil
+			}
+			continue
+		}
+		lost, err := s.lead(ctx, r, lease)
+		_ = lease.Unlock()
+		if !lost {
+			return err
+		}
+	}
+	return nil
+}
+
+// lead runs the inner server while holding lease. It returns lost if the
+// lease was lost, otherwise the inner server stopped or failed.
+func (s *Server) lead(ctx context.Context, r *run, lease Lease) (lost bool, err error) {
+	running := make(chan error, 1)
+	s.setLeader(true)
+	defer s.setLeader(false)
+	go func() { running <- s.inner.Start(ctx) }()
+
+	select {
+	case err := <-running:
+		return false, err
+	case <-ctx.Done():
+		select {
+		case err := <-running:
+			return false, err
+		case <-r.stopping:
+		}
+		r.stopErr = s.inner.Stop(r.stopCtx)
+		select {
+		case err := <-running:
+			return false, err
+		case <-r.stopCtx.Done():
+			return false, nil // Stop reports the timeout
+		}
+	case <-lease.Lost():
+		stopCtx, cancel := context.WithTimeoutCause(context.WithoutCancel(ctx), s.stopTimeout, ErrLeaseLost)
+		defer cancel()
+		_ = s.inner.Stop(stopCtx)
+		// the lease is acquired again only once the inner server returned,
+		// so that two instances never run at once even if Stop missed its deadline
+		if err := <-running; ctx.Err() != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+// Stop stops the inner server if it is running and waits for Start to return.
+func (s *Server) Stop(ctx context.Context) error {
+	r := s.current(false)
+	r.stopOnce.Do(func() {
+		r.stopCtx = ctx
+		close(r.stopping)
+	})
+	select {
+	case <-r.exited:
+		return r.stopErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) setLeader(leader bool) {
+	s.leader.Store(leader)
+	for _, fn := range s.onChange {
+		fn(leader)
+	}
+}
+
+// sleep waits for d, it returns false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
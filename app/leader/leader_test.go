@@ -0,0 +1,196 @@
+//go:build !windows
+
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package leader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// worker is a restartable server.Server counting its starts.
+type worker struct {
+	mu     sync.Mutex
+	starts int
+	stop   chan struct{}
+}
+
+func (w *worker) Start(ctx context.Context) error {
+	w.mu.Lock()
+	w.starts++
+	stop := make(chan struct{})
+	w.stop = stop
+	w.mu.Unlock()
+	<-stop
+	return nil
+}
+
+func (w *worker) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+	return nil
+}
+
+func (w *worker) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.starts
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServer_Failover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	w1, w2 := &worker{}, &worker{}
+	s1 := New(w1, NewFileLocker(path, 5*time.Millisecond), RetryInterval(5*time.Millisecond))
+	s2 := New(w2, NewFileLocker(path, 5*time.Millisecond), RetryInterval(5*time.Millisecond))
+
+	errc := make(chan error, 2)
+	go func() { errc <- s1.Start(context.Background()) }()
+	eventually(t, "s1 to lead", s1.IsLeader)
+	go func() { errc <- s2.Start(context.Background()) }()
+	time.Sleep(30 * time.Millisecond)
+	if s2.IsLeader() || w2.count() != 0 {
+		t.Fatal("s2 leads while s1 holds the lease")
+	}
+
+	if err := s1.Stop(context.Background()); err != nil {
+		t.Fatalf("s1 Stop() error = %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("s1 Start() error = %v", err)
+	}
+	// IsLeader is set before the inner server is started
+	eventually(t, "s2 to take over", func() bool { return s2.IsLeader() && w2.count() == 1 })
+
+	// the lease is lost when the lock file is removed, s2 stops w2 and leads again
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "w2 to be restarted", func() bool { return w2.count() == 2 && s2.IsLeader() })
+
+	if err := s2.Stop(context.Background()); err != nil {
+		t.Fatalf("s2 Stop() error = %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("s2 Start() error = %v", err)
+	}
+	if s2.IsLeader() {
+		t.Error("s2 still leads after Stop")
+	}
+}
+
+func TestServer_StopStandby(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	holder, err := NewFileLocker(path, 0).Lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Unlock()
+
+	w := &worker{}
+	s := New(w, NewFileLocker(path, 5*time.Millisecond))
+	errc := make(chan error, 1)
+	go func() { errc <- s.Start(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := <-errc; err != nil || w.count() != 0 {
+		t.Errorf("Start() = %v with %d starts, want the standby to exit", err, w.count())
+	}
+}
+
+// oneShot is a server.Server that cannot be restarted.
+type oneShot struct{ worker }
+
+func (*oneShot) Restartable() bool { return false }
+
+func TestNew_NotRestartable(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("New() did not panic")
+		}
+	}()
+	New(&oneShot{}, nil)
+}
+
+func TestServer_Restart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	w := &worker{}
+	s := New(w, NewFileLocker(path, 5*time.Millisecond))
+	for i := 1; i <= 2; i++ {
+		errc := make(chan error, 1)
+		go func() { errc <- s.Start(context.Background()) }()
+		eventually(t, "the inner server to start", func() bool { return w.count() == i })
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatalf("run %d: Stop() error = %v", i, err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("run %d: Start() error = %v", i, err)
+		}
+	}
+}
+
+// stuckWorker is a worker whose Start ignores Stop until released.
+type stuckWorker struct {
+	worker
+	release chan struct{}
+}
+
+func (w *stuckWorker) Start(ctx context.Context) error {
+	_ = w.worker.Start(ctx)
+	<-w.release
+	return nil
+}
+
+func TestServer_LeaseLostStopTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	w := &stuckWorker{release: make(chan struct{})}
+	s := New(w, NewFileLocker(path, 5*time.Millisecond), StopTimeout(10*time.Millisecond))
+	errc := make(chan error, 1)
+	go func() { errc <- s.Start(context.Background()) }()
+	eventually(t, "w to start", func() bool { return w.count() == 1 })
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond) // well past the stop timeout
+	if n := w.count(); n != 1 {
+		t.Fatalf("starts = %d while the previous Start is still running, want 1", n)
+	}
+	close(w.release)
+	eventually(t, "w to be restarted", func() bool { return w.count() == 2 })
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+// Package logadmin provides a server.Server serving the runtime log level
+// admin endpoint of the log package.
+package logadmin
+
+import (
+	"context"
+	"github.com/go-inspire/pkg/app/server"
+	"github.com/go-inspire/pkg/app/server/adapter"
+	"github.com/go-inspire/pkg/log"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+var _ server.Server = (*Server)(nil)
+var _ server.Readier = (*Server)(nil)
+var _ server.Endpointer = (*Server)(nil)
+
+// Option is a log admin server option.
+type Option func(s *Server)
+
+// Address with the listen address, "127.0.0.1:8082" by default, as the
+// endpoint changes the log levels of the application.
+func Address(addr string) Option {
+	return func(s *Server) { s.addr = addr }
+}
+
+// Path with the endpoint path, "/loggers" by default.
+func Path(path string) Option {
+	return func(s *Server) { s.path = path }
+}
+
+// Middleware wraps the log.Handler, for example to authenticate requests.
+func Middleware(m func(http.Handler) http.Handler) Option {
+	return func(s *Server) { s.middleware = append(s.middleware, m) }
+}
+
+// Server serves log.Handler: GET lists the global log config, the levels of
+// all log.Named components and the pending temporary levels, and PUT changes
+// the level of a component or prefix, optionally for a TTL.
+type Server struct {
+	addr       string
+	path       string
+	middleware []func(http.Handler) http.Handler
+
+	srv *adapter.HTTP
+}
+
+// New creates a log admin server.
+func New(opts ...Option) *Server {
+	s := &Server{
+		addr: "127.0.0.1:8082",
+		path: "/loggers",
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	h := log.Handler()
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		h = s.middleware[i](h)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(s.path, h)
+	s.srv = adapter.NewHTTP(&http.Server{Addr: s.addr, Handler: mux})
+	return s
+}
+
+// Start listens on the address and serves the endpoint until stopped.
+func (s *Server) Start(ctx context.Context) error {
+	return s.srv.Start(ctx)
+}
+
+// Stop gracefully shuts down the server.
+func (s *Server) Stop(ctx context.Context) error {
+	return s.srv.Stop(ctx)
+}
+
+// Ready implements server.Readier, it is closed once the log levels can be
+// read and changed at the admin address.
+func (s *Server) Ready() <-chan struct{} {
+	return s.srv.Ready()
+}
+
+// Addr returns the address the log level endpoint listens on, or nil before
+// Start, for example to find the port chosen for Address("127.0.0.1:0").
+func (s *Server) Addr() net.Addr {
+	return s.srv.Addr()
+}
+
+// Endpoints implements server.Endpointer with the http URL of the admin
+// listener, without the endpoint Path, or nil before Start.
+func (s *Server) Endpoints() []*url.URL {
+	return s.srv.Endpoints()
+}
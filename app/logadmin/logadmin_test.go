@@ -0,0 +1,62 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package logadmin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-inspire/pkg/app"
+	"github.com/go-inspire/pkg/log"
+)
+
+func TestServer(t *testing.T) {
+	var authorized []string
+	s := New(Address("127.0.0.1:0"), Middleware(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorized = append(authorized, r.Method)
+			h.ServeHTTP(w, r)
+		})
+	}))
+	a := app.New(app.Name("logadmin-test"), app.Server(s))
+	done := make(chan error, 1)
+	go func() { done <- a.Run() }()
+	<-s.Ready()
+	url := "http://" + s.Addr().String() + "/loggers"
+
+	component := log.Named("logadmin.test")
+	req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(`{"name":"logadmin","level":"error"}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var levels log.LevelsResponse
+	err = json.NewDecoder(resp.Body).Decode(&levels)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT = %d, %v", resp.StatusCode, err)
+	}
+	if component.Level() != log.ErrorLevel || levels.Config.Named["logadmin"] != log.ErrorLevel {
+		t.Errorf("level = %v, config = %+v, want error", component.Level(), levels.Config)
+	}
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(authorized) != 2 || authorized[1] != http.MethodGet {
+		t.Errorf("middleware saw %v, want PUT and GET", authorized)
+	}
+
+	_ = a.Stop()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
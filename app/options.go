@@ -8,8 +8,13 @@ package app
 
 import (
 	"context"
+	"github.com/go-inspire/pkg/app/registry"
 	"github.com/go-inspire/pkg/app/server"
+	"github.com/go-inspire/pkg/config"
+	"github.com/go-inspire/pkg/log"
+	"net/url"
 	"os"
+	"time"
 )
 
 // Option is an application option.
@@ -17,15 +22,33 @@ type Option func(o *options)
 
 // options is an application options.
 type options struct {
-	id       string
-	name     string
-	version  string
-	metadata map[string]string
+	id        string
+	name      string
+	version   string
+	metadata  map[string]string
+	endpoints []*url.URL
 
-	ctx  context.Context
-	sigs []os.Signal
+	ctx          context.Context
+	logger       *log.Adapter
+	config       config.Source
+	sigs         []os.Signal
+	handlers     map[os.Signal]SignalHandler
+	notifier     SignalNotifier
+	stopTimeout  time.Duration
+	readyTimeout time.Duration
 
-	servers []server.Server
+	registrar        registry.Registrar
+	registrarTimeout time.Duration
+
+	servers      []server.Server
+	deps         []dependency
+	supervisions []supervision
+	onRestart    []func(RestartEvent)
+
+	beforeStart []func(context.Context) error
+	afterStart  []func(context.Context) error
+	beforeStop  []func(context.Context) error
+	afterStop   []func(context.Context) error
 }
 
 // ID with service id.
@@ -48,17 +71,114 @@ func Metadata(md map[string]string) Option {
 	return func(o *options) { o.metadata = md }
 }
 
+// Endpoint with service endpoints, published to the Registrar instead of the
+// endpoints reported by the servers, such as the address of a proxy.
+func Endpoint(endpoints ...*url.URL) Option {
+	return func(o *options) { o.endpoints = endpoints }
+}
+
 // Context with service context.
 func Context(ctx context.Context) Option {
 	return func(o *options) { o.ctx = ctx }
 }
 
+// Config with the application configuration. Servers read it from the
+// context passed to Start and Stop with config.FromContext.
+func Config(src config.Source) Option {
+	return func(o *options) { o.config = src }
+}
+
+// Logger with the logger of the lifecycle events, log.Named("app") by default.
+// The app ID, name and version are attached to every record.
+func Logger(logger *log.Adapter) Option {
+	return func(o *options) { o.logger = logger }
+}
+
 // Signal with exit signals.
 func Signal(sigs ...os.Signal) Option {
 	return func(o *options) { o.sigs = sigs }
 }
 
+// HandleSignal with the handler of a non-stop signal, replacing the default
+// handler of that signal: SIGHUP reloads the app and SIGUSR1 dumps the App
+// state and the goroutine stacks. A nil handler ignores the signal.
+func HandleSignal(sig os.Signal, h SignalHandler) Option {
+	return func(o *options) {
+		if o.handlers == nil {
+			o.handlers = make(map[os.Signal]SignalHandler)
+		}
+		o.handlers[sig] = h
+	}
+}
+
+// Notifier with the source of the signals, the process signals by default.
+func Notifier(n SignalNotifier) Option {
+	return func(o *options) { o.notifier = n }
+}
+
+// StopTimeout with the deadline of each server's Stop.
+// Servers that miss it are reported by Run with ErrStopTimeout.
+func StopTimeout(d time.Duration) Option {
+	return func(o *options) { o.stopTimeout = d }
+}
+
+// ReadyTimeout with the maximum time to wait for a server.Readier to be ready,
+// 30 seconds by default. Zero waits without limit.
+func ReadyTimeout(d time.Duration) Option {
+	return func(o *options) { o.readyTimeout = d }
+}
+
+// Registrar with the service registrar. The app instance is registered once
+// all servers are ready and deregistered before the first server stops.
+func Registrar(r registry.Registrar) Option {
+	return func(o *options) { o.registrar = r }
+}
+
+// RegistrarTimeout with the deadline of Register and Deregister, 10 seconds by default.
+// Zero waits without limit.
+func RegistrarTimeout(d time.Duration) Option {
+	return func(o *options) { o.registrarTimeout = d }
+}
+
 // Server with transport servers.
 func Server(srv ...server.Server) Option {
 	return func(o *options) { o.servers = srv }
 }
+
+// Depend declares that srv depends on deps: srv is started after all of deps
+// and stopped before any of them. Both srv and deps must be registered with Server.
+func Depend(srv server.Server, deps ...server.Server) Option {
+	return func(o *options) { o.deps = append(o.deps, dependency{srv: srv, deps: deps}) }
+}
+
+// Supervise with the restart policy of srv, which must be registered with Server.
+// Servers without a policy are never restarted. Run fails if srv implements
+// server.Restartable and cannot be restarted.
+func Supervise(srv server.Server, policy RestartPolicy) Option {
+	return func(o *options) { o.supervisions = append(o.supervisions, supervision{srv: srv, policy: policy}) }
+}
+
+// OnRestart with a func notified of the restarts of supervised servers.
+func OnRestart(fn func(RestartEvent)) Option {
+	return func(o *options) { o.onRestart = append(o.onRestart, fn) }
+}
+
+// BeforeStart run funcs before app starts. A failing func aborts Run.
+func BeforeStart(fn func(context.Context) error) Option {
+	return func(o *options) { o.beforeStart = append(o.beforeStart, fn) }
+}
+
+// AfterStart run funcs after app starts. A failing func stops the app.
+func AfterStart(fn func(context.Context) error) Option {
+	return func(o *options) { o.afterStart = append(o.afterStart, fn) }
+}
+
+// BeforeStop run funcs before app stops.
+func BeforeStop(fn func(context.Context) error) Option {
+	return func(o *options) { o.beforeStop = append(o.beforeStop, fn) }
+}
+
+// AfterStop run funcs after app stops, even if a server failed.
+func AfterStop(fn func(context.Context) error) Option {
+	return func(o *options) { o.afterStop = append(o.afterStop, fn) }
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package registry
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+var (
+	_ Registrar = (*Memory)(nil)
+	_ Discovery = (*Memory)(nil)
+)
+
+// Memory is an in-memory Registrar and Discovery, shared by the apps and
+// clients of a process.
+type Memory struct {
+	mu       sync.Mutex
+	services map[string]map[string]*ServiceInstance // name -> id -> instance
+	watchers map[string]map[*memoryWatcher]struct{}
+}
+
+// NewMemory creates an empty in-memory registry.
+func NewMemory() *Memory {
+	return &Memory{
+		services: make(map[string]map[string]*ServiceInstance),
+		watchers: make(map[string]map[*memoryWatcher]struct{}),
+	}
+}
+
+// Register implements Registrar.
+func (m *Memory) Register(ctx context.Context, ins *ServiceInstance) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.services[ins.Name]
+	if !ok {
+		s = make(map[string]*ServiceInstance)
+		m.services[ins.Name] = s
+	}
+	s[ins.ID] = clone(ins)
+	m.notify(ins.Name)
+	return nil
+}
+
+// Deregister implements Registrar.
+func (m *Memory) Deregister(ctx context.Context, ins *ServiceInstance) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.services[ins.Name]
+	if !ok {
+		return nil
+	}
+	if _, ok := s[ins.ID]; !ok {
+		return nil
+	}
+	delete(s, ins.ID)
+	if len(s) == 0 {
+		delete(m.services, ins.Name)
+	}
+	m.notify(ins.Name)
+	return nil
+}
+
+// GetService implements Discovery, the instances are sorted by ID.
+func (m *Memory) GetService(ctx context.Context, name string) ([]*ServiceInstance, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.instances(name), nil
+}
+
+// Watch implements Discovery. The watcher is stopped when ctx is done.
+func (m *Memory) Watch(ctx context.Context, name string) (Watcher, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w := &memoryWatcher{
+		m:       m,
+		name:    name,
+		ctx:     ctx,
+		cancel:  cancel,
+		changed: make(chan struct{}, 1),
+	}
+	w.changed <- struct{}{} // the first Next returns the current instances
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ws, ok := m.watchers[name]
+	if !ok {
+		ws = make(map[*memoryWatcher]struct{})
+		m.watchers[name] = ws
+	}
+	ws[w] = struct{}{}
+	context.AfterFunc(ctx, func() { m.remove(w) })
+	return w, nil
+}
+
+// instances returns copies of the instances of the service, m.mu must be held.
+func (m *Memory) instances(name string) []*ServiceInstance {
+	s := m.services[name]
+	result := make([]*ServiceInstance, 0, len(s))
+	for _, ins := range s {
+		result = append(result, clone(ins))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// notify wakes up the watchers of the service, m.mu must be held.
+func (m *Memory) notify(name string) {
+	for w := range m.watchers[name] {
+		select {
+		case w.changed <- struct{}{}:
+		default: // a change is already pending
+		}
+	}
+}
+
+// remove removes the watcher.
+func (m *Memory) remove(w *memoryWatcher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ws, ok := m.watchers[w.name]; ok {
+		delete(ws, w)
+		if len(ws) == 0 {
+			delete(m.watchers, w.name)
+		}
+	}
+}
+
+// memoryWatcher is the Watcher of Memory.
+type memoryWatcher struct {
+	m       *Memory
+	name    string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	changed chan struct{}
+}
+
+func (w *memoryWatcher) Next() ([]*ServiceInstance, error) {
+	select {
+	case <-w.ctx.Done():
+		return nil, ErrWatcherStopped
+	case <-w.changed:
+	}
+	if w.ctx.Err() != nil {
+		return nil, ErrWatcherStopped
+	}
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+	return w.m.instances(w.name), nil
+}
+
+func (w *memoryWatcher) Stop() error {
+	w.cancel() // removes the watcher from the registry
+	return nil
+}
+
+// clone returns a copy of ins, so callers cannot modify the registry.
+func clone(ins *ServiceInstance) *ServiceInstance {
+	c := *ins
+	if ins.Metadata != nil {
+		c.Metadata = make(map[string]string, len(ins.Metadata))
+		for k, v := range ins.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	c.Endpoints = append([]string(nil), ins.Endpoints...)
+	return &c
+}
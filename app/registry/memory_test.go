@@ -0,0 +1,98 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func next(t *testing.T, w Watcher) []*ServiceInstance {
+	t.Helper()
+	type result struct {
+		list []*ServiceInstance
+		err  error
+	}
+	c := make(chan result, 1)
+	go func() {
+		list, err := w.Next()
+		c <- result{list, err}
+	}()
+	select {
+	case r := <-c:
+		if r.err != nil {
+			t.Fatalf("Next() error = %v", r.err)
+		}
+		return r.list
+	case <-time.After(time.Second):
+		t.Fatal("Next() did not return")
+		return nil
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	a := &ServiceInstance{ID: "a", Name: "svc", Endpoints: []string{"http://127.0.0.1:8000"}}
+	b := &ServiceInstance{ID: "b", Name: "svc"}
+
+	if err := m.Register(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	w, err := m.Watch(ctx, "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := next(t, w); len(list) != 1 || list[0].ID != "b" {
+		t.Errorf("first Next() = %v, want [b]", list)
+	}
+
+	if err := m.Register(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if list := next(t, w); len(list) != 2 || list[0].ID != "a" || list[0].Endpoints[0] != "http://127.0.0.1:8000" {
+		t.Errorf("Next() = %v, want [a b]", list)
+	}
+
+	if err := m.Deregister(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if list := next(t, w); len(list) != 1 || list[0].ID != "a" {
+		t.Errorf("Next() = %v, want [a]", list)
+	}
+	list, err := m.GetService(ctx, "svc")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("GetService() = %v, %v", list, err)
+	}
+	list[0].Endpoints[0] = "modified"
+	if list, _ := m.GetService(ctx, "svc"); list[0].Endpoints[0] != "http://127.0.0.1:8000" {
+		t.Error("GetService() returned the registered instance, not a copy")
+	}
+
+	if err := w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Next(); !errors.Is(err, ErrWatcherStopped) {
+		t.Errorf("Next() after Stop error = %v, want ErrWatcherStopped", err)
+	}
+}
+
+func TestMemory_WatchContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewMemory()
+	w, err := m.Watch(ctx, "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next(t, w)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := w.Next(); !errors.Is(err, ErrWatcherStopped) {
+		t.Errorf("Next() error = %v, want ErrWatcherStopped", err)
+	}
+}
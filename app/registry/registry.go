@@ -0,0 +1,58 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+// Package registry defines the service registration and discovery interfaces
+// used by app.App, with an in-memory implementation for tests and local setups.
+package registry
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrWatcherStopped is returned by Watcher.Next once the watcher is stopped.
+var ErrWatcherStopped = errors.New("registry: watcher stopped")
+
+// ServiceInstance is an instance of a service in the registry.
+type ServiceInstance struct {
+	// ID is the unique instance id, the app.App ID.
+	ID string `json:"id"`
+	// Name is the service name.
+	Name string `json:"name"`
+	// Version is the service version.
+	Version string `json:"version"`
+	// Metadata is the service metadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Endpoints are the endpoint URLs of the instance, such as
+	// http://127.0.0.1:8000 or tcp://127.0.0.1:9000.
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// Registrar registers service instances.
+type Registrar interface {
+	// Register registers the instance, replacing an instance with the same ID.
+	Register(ctx context.Context, ins *ServiceInstance) error
+	// Deregister removes the instance.
+	Deregister(ctx context.Context, ins *ServiceInstance) error
+}
+
+// Discovery resolves service instances.
+type Discovery interface {
+	// GetService returns the instances of the service.
+	GetService(ctx context.Context, name string) ([]*ServiceInstance, error)
+	// Watch creates a watcher of the instances of the service.
+	Watch(ctx context.Context, name string) (Watcher, error)
+}
+
+// Watcher watches the instances of a service.
+type Watcher interface {
+	// Next returns the instances of the service. The first call returns
+	// immediately, later calls block until the instances change, the
+	// watcher is stopped or the watch context is done.
+	Next() ([]*ServiceInstance, error)
+	// Stop stops the watcher.
+	Stop() error
+}
@@ -0,0 +1,25 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package adapter
+
+import (
+	"net"
+	"net/url"
+)
+
+// endpoints 返回监听地址 addr 对应的地址列表，unix 套接字使用 unix 协议，其他使用 scheme
+func endpoints(scheme string, addr net.Addr) []*url.URL {
+	if addr == nil {
+		return nil
+	}
+	switch addr.Network() {
+	case "unix", "unixpacket":
+		return []*url.URL{{Scheme: "unix", Path: addr.String()}}
+	default:
+		return []*url.URL{{Scheme: scheme, Host: addr.String()}}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package adapter
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/go-inspire/pkg/app/server"
+)
+
+var (
+	_ server.Server      = (*GRPC)(nil)
+	_ server.Readier     = (*GRPC)(nil)
+	_ server.Endpointer  = (*GRPC)(nil)
+	_ server.Restartable = (*GRPC)(nil)
+)
+
+// GRPCServer 是 *grpc.Server 风格的服务接口
+type GRPCServer interface {
+	// Serve 在 lis 上接受连接，直到服务停止
+	Serve(lis net.Listener) error
+	// GracefulStop 停止接受新连接，并等待进行中的请求完成
+	GracefulStop()
+	// Stop 立即关闭所有连接
+	Stop()
+}
+
+// GRPC 将 gRPC 风格的服务适配为 server.Server
+// gRPC 服务停止后不能再次 Serve，因此 GRPC 不能再次启动，不能用于重启策略和 leader.Server
+type GRPC struct {
+	srv      GRPCServer
+	lis      net.Listener
+	ready    chan struct{}
+	stopping atomic.Bool
+}
+
+// NewGRPC 创建 gRPC 风格服务的适配器，lis 由 srv 负责关闭
+func NewGRPC(srv GRPCServer, lis net.Listener) *GRPC {
+	return &GRPC{
+		srv:   srv,
+		lis:   lis,
+		ready: make(chan struct{}),
+	}
+}
+
+// Start 在监听上处理请求，直到服务停止
+func (s *GRPC) Start(ctx context.Context) error {
+	select {
+	case <-s.ready:
+	default:
+		close(s.ready)
+	}
+	if err := s.srv.Serve(s.lis); err != nil && !s.stopping.Load() {
+		return err
+	}
+	return nil
+}
+
+// Stop 优雅停止服务；ctx 到期后强制停止，并返回 ctx 的错误
+func (s *GRPC) Stop(ctx context.Context) error {
+	s.stopping.Store(true)
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.srv.Stop()
+		return ctx.Err()
+	}
+}
+
+// Restartable 实现 server.Restartable，GRPC 不能再次启动
+func (s *GRPC) Restartable() bool {
+	return false
+}
+
+// Ready 实现 server.Readier，服务开始处理请求后关闭
+func (s *GRPC) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Addr 返回监听地址
+func (s *GRPC) Addr() net.Addr {
+	return s.lis.Addr()
+}
+
+// Endpoints 实现 server.Endpointer，返回 grpc 协议的监听地址，unix 套接字返回 unix 协议的地址
+func (s *GRPC) Endpoints() []*url.URL {
+	return endpoints("grpc", s.Addr())
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGRPC is a GRPCServer whose GracefulStop waits for release.
+type fakeGRPC struct {
+	lis     net.Listener
+	release chan struct{}
+	once    sync.Once
+	forced  chan struct{}
+}
+
+func (s *fakeGRPC) Serve(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		conn.Close()
+	}
+}
+
+func (s *fakeGRPC) GracefulStop() {
+	s.lis.Close()
+	<-s.release
+}
+
+func (s *fakeGRPC) Stop() {
+	s.once.Do(func() { close(s.forced) })
+}
+
+func TestGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeGRPC{lis: lis, release: make(chan struct{}), forced: make(chan struct{})}
+	defer close(fake.release)
+	srv := NewGRPC(fake, lis)
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Start(context.Background()) }()
+	<-srv.Ready()
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := srv.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case <-fake.forced:
+	default:
+		t.Error("Stop() did not force the stop after the deadline")
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Start() error = %v, want nil after stop", err)
+	}
+}
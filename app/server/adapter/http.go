@@ -0,0 +1,176 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+// Package adapter 提供将常见服务适配为 server.Server 的实现，
+// 包括 *http.Server、net.Listener 的 accept 循环、gRPC 风格的服务、后台任务和定时任务。
+package adapter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/go-inspire/pkg/app/server"
+)
+
+var (
+	_ server.Server     = (*HTTP)(nil)
+	_ server.Readier    = (*HTTP)(nil)
+	_ server.Endpointer = (*HTTP)(nil)
+)
+
+// HTTP 将 *http.Server 适配为 server.Server
+// 关闭的 *http.Server 不能再次使用，再次 Start 时按 NewHTTP 传入的配置创建新的
+type HTTP struct {
+	conf     *http.Server
+	base     func(net.Listener) context.Context // conf 原有的 BaseContext
+	tls      bool                               // 是否提供 HTTPS 服务
+	certFile string
+	keyFile  string
+	ready    chan struct{}
+
+	mu   sync.Mutex
+	srv  *http.Server // 当前使用的服务
+	used bool         // srv 是否已启动过
+	lis  net.Listener
+}
+
+// NewHTTP 创建 *http.Server 的适配器，服务监听 srv.Addr，为空时监听 ":http"
+// srv.TLSConfig 不为 nil 时使用其中的证书提供 HTTPS 服务，同 NewHTTPS(srv, "", "")
+func NewHTTP(srv *http.Server) *HTTP {
+	if srv.TLSConfig != nil {
+		return NewHTTPS(srv, "", "")
+	}
+	return &HTTP{
+		conf:  srv,
+		base:  srv.BaseContext,
+		ready: make(chan struct{}),
+		srv:   srv,
+	}
+}
+
+// NewHTTPS 创建提供 HTTPS 服务的 *http.Server 适配器，服务监听 srv.Addr，为空时监听 ":https"
+// 证书和私钥从 certFile 和 keyFile 读取，srv.TLSConfig 中已设置证书时可以为空，同 http.Server.ServeTLS
+func NewHTTPS(srv *http.Server, certFile, keyFile string) *HTTP {
+	return &HTTP{
+		conf:     srv,
+		base:     srv.BaseContext,
+		tls:      true,
+		certFile: certFile,
+		keyFile:  keyFile,
+		ready:    make(chan struct{}),
+		srv:      srv,
+	}
+}
+
+// Start 监听地址并处理请求，直到服务停止
+// 未设置 BaseContext 时，请求的上下文继承 ctx 中的值（如 AppInfo），但不随 ctx 取消，
+// 以便停止时处理完进行中的请求
+func (s *HTTP) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.used {
+		s.srv = s.clone()
+	}
+	s.used = true
+	srv := s.srv
+	s.mu.Unlock()
+
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":" + s.scheme()
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if s.base == nil {
+		base := context.WithoutCancel(ctx)
+		srv.BaseContext = func(net.Listener) context.Context { return base }
+	}
+	s.mu.Lock()
+	s.lis = lis
+	s.mu.Unlock()
+	select {
+	case <-s.ready:
+	default:
+		close(s.ready)
+	}
+
+	if s.tls {
+		err = srv.ServeTLS(lis, s.certFile, s.keyFile)
+	} else {
+		err = srv.Serve(lis)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// scheme 返回服务的协议，http 或 https
+func (s *HTTP) scheme() string {
+	if s.tls {
+		return "https"
+	}
+	return "http"
+}
+
+// clone 按 NewHTTP 传入的配置创建新的 *http.Server
+func (s *HTTP) clone() *http.Server {
+	c := s.conf
+	return &http.Server{
+		Addr:                         c.Addr,
+		Handler:                      c.Handler,
+		DisableGeneralOptionsHandler: c.DisableGeneralOptionsHandler,
+		TLSConfig:                    c.TLSConfig,
+		ReadTimeout:                  c.ReadTimeout,
+		ReadHeaderTimeout:            c.ReadHeaderTimeout,
+		WriteTimeout:                 c.WriteTimeout,
+		IdleTimeout:                  c.IdleTimeout,
+		MaxHeaderBytes:               c.MaxHeaderBytes,
+		TLSNextProto:                 c.TLSNextProto,
+		ConnState:                    c.ConnState,
+		ErrorLog:                     c.ErrorLog,
+		BaseContext:                  s.base,
+		ConnContext:                  c.ConnContext,
+	}
+}
+
+// Stop 优雅关闭服务，等待进行中的请求处理完成；
+// ctx 到期后强制关闭所有连接，并返回 ctx 的错误
+func (s *HTTP) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	srv := s.srv
+	s.mu.Unlock()
+	err := srv.Shutdown(ctx)
+	if err != nil && ctx.Err() != nil {
+		_ = srv.Close()
+	}
+	return err
+}
+
+// Ready 实现 server.Readier，服务第一次开始监听后关闭
+func (s *HTTP) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Addr 返回监听地址，服务未启动时返回 nil
+func (s *HTTP) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lis == nil {
+		return nil
+	}
+	return s.lis.Addr()
+}
+
+// Endpoints 实现 server.Endpointer，返回 http 或 https 协议的监听地址，服务未启动时返回 nil
+func (s *HTTP) Endpoints() []*url.URL {
+	return endpoints(s.scheme(), s.Addr())
+}
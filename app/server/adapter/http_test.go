@@ -0,0 +1,188 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package adapter
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTP(t *testing.T) {
+	type key struct{}
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	srv := NewHTTP(&http.Server{
+		Addr: "127.0.0.1:0",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				close(entered)
+				<-release
+			}
+			_, _ = io.WriteString(w, r.Context().Value(key{}).(string))
+		}),
+	})
+
+	if eps := srv.Endpoints(); eps != nil {
+		t.Errorf("Endpoints() before Start = %v, want nil", eps)
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "app"))
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Start(ctx) }()
+	<-srv.Ready()
+	base := "http://" + srv.Addr().String()
+	if eps := srv.Endpoints(); len(eps) != 1 || eps[0].String() != base {
+		t.Errorf("Endpoints() = %v, want [%s]", eps, base)
+	}
+
+	resp, err := http.Get(base + "/")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "app" {
+		t.Errorf("body = %q, want the request context to carry the start context values", body)
+	}
+
+	// an in-flight request is drained by a graceful stop
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-entered
+	cancel()
+	stopped := make(chan error, 1)
+	go func() { stopped <- srv.Stop(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if got := <-slow; got != "app" {
+		t.Errorf("in-flight response = %q, want it drained", got)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+}
+
+func TestHTTP_StopTimeout(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	srv := NewHTTP(&http.Server{
+		Addr: "127.0.0.1:0",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(entered)
+			<-release
+		}),
+	})
+	go func() { _ = srv.Start(context.Background()) }()
+	<-srv.Ready()
+	go func() { _, _ = http.Get("http://" + srv.Addr().String()) }()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := srv.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestHTTP_Restart(t *testing.T) {
+	srv := NewHTTP(&http.Server{
+		Addr: "127.0.0.1:0",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "ok")
+		}),
+	})
+	// get 等待服务在当前的监听地址上处理请求，再次启动时 Ready 已经关闭，地址在重新监听后更新
+	get := func() error {
+		var err error
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if addr := srv.Addr(); addr != nil {
+				var resp *http.Response
+				if resp, err = http.Get("http://" + addr.String()); err == nil {
+					resp.Body.Close()
+					return nil
+				}
+			}
+		}
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		errc := make(chan error, 1)
+		go func() { errc <- srv.Start(context.Background()) }()
+		<-srv.Ready()
+		if err := get(); err != nil {
+			t.Fatalf("start %d: GET error = %v", i, err)
+		}
+		if err := srv.Stop(context.Background()); err != nil {
+			t.Errorf("start %d: Stop() error = %v", i, err)
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("start %d: Start() error = %v", i, err)
+		}
+	}
+}
+
+func TestHTTP_TLS(t *testing.T) {
+	// 借用 httptest 的自签名证书，ts.Client() 信任该证书
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	ts.Close()
+	srv := NewHTTP(&http.Server{
+		Addr:      "127.0.0.1:0",
+		TLSConfig: &tls.Config{Certificates: ts.TLS.Certificates},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "ok")
+		}),
+	})
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Start(context.Background()) }()
+	<-srv.Ready()
+	base := "https://" + srv.Addr().String()
+	if eps := srv.Endpoints(); len(eps) != 1 || eps[0].String() != base {
+		t.Errorf("Endpoints() = %v, want [%s]", eps, base)
+	}
+
+	resp, err := ts.Client().Get(base)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" || resp.TLS == nil {
+		t.Errorf("body = %q over TLS %v, want ok over TLS", body, resp.TLS != nil)
+	}
+	if resp, err := http.Get("http://" + srv.Addr().String()); err == nil {
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("plaintext GET status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+		}
+		resp.Body.Close()
+	}
+
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-inspire/pkg/app/server"
+)
+
+var (
+	_ server.Server     = (*Listener)(nil)
+	_ server.Readier    = (*Listener)(nil)
+	_ server.Endpointer = (*Listener)(nil)
+)
+
+// ConnHandler 处理一个连接，ctx 在服务被强制停止时取消
+type ConnHandler func(ctx context.Context, conn net.Conn)
+
+// Listener 将 net.Listener 的 accept 循环适配为 server.Server，
+// 每个连接由独立的 goroutine 交给 ConnHandler 处理，再次 Start 时在原有的地址上重新监听
+type Listener struct {
+	handler  ConnHandler
+	ready    chan struct{}
+	stopping atomic.Bool
+
+	mu     sync.Mutex
+	lis    net.Listener
+	used   bool // lis 是否已启动过
+	conns  map[net.Conn]struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewListener 创建 net.Listener 的适配器，lis 由适配器负责关闭
+func NewListener(lis net.Listener, handler ConnHandler) *Listener {
+	return &Listener{
+		lis:     lis,
+		handler: handler,
+		ready:   make(chan struct{}),
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Start 接受连接并交给 ConnHandler 处理，直到服务停止
+// 连接的上下文继承 ctx 中的值，但不随 ctx 取消
+func (s *Listener) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.used {
+		addr := s.lis.Addr()
+		_ = s.lis.Close()
+		lis, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		s.lis = lis
+		s.stopping.Store(false)
+	}
+	s.used = true
+	lis := s.lis
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.cancel = cancel
+	s.mu.Unlock()
+	select {
+	case <-s.ready:
+	default:
+		close(s.ready)
+	}
+
+	var delay time.Duration
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if s.stopping.Load() {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				// 临时错误，退避后重试
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+		if !s.track(conn) {
+			_ = conn.Close()
+			return nil
+		}
+		go func() {
+			defer s.untrack(conn)
+			s.handler(ctx, conn)
+		}()
+	}
+}
+
+// Stop 关闭监听并等待所有连接处理完成；
+// ctx 到期后强制关闭所有连接，并返回 ctx 的错误
+func (s *Listener) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopping.Store(true)
+	lis := s.lis
+	s.mu.Unlock()
+	err := lis.Close()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.mu.Lock()
+		s.mu.Unlock() // 等待进行中的 track 完成
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		if s.cancel != nil {
+			s.cancel()
+		}
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Ready 实现 server.Readier，服务第一次开始接受连接后关闭
+func (s *Listener) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Addr 返回监听地址
+func (s *Listener) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lis.Addr()
+}
+
+// track 记录连接，服务停止后返回 false
+func (s *Listener) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping.Load() {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// untrack 关闭并移除连接
+func (s *Listener) untrack(conn net.Conn) {
+	_ = conn.Close()
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// Endpoints 实现 server.Endpointer，返回 tcp 协议的监听地址，unix 套接字返回 unix 协议的地址
+func (s *Listener) Endpoints() []*url.URL {
+	return endpoints("tcp", s.Addr())
+}
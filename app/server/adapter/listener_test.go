@@ -0,0 +1,138 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewListener(lis, func(ctx context.Context, conn net.Conn) {
+		_, _ = io.Copy(conn, conn) // echo
+	})
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Start(context.Background()) }()
+	<-srv.Ready()
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.WriteString(conn, "ping\n")
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Errorf("echo = %q, %v, want ping", line, err)
+	}
+
+	// the open connection is closed once the stop deadline passes
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := srv.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read() error = %v, want the connection closed", err)
+	}
+	conn.Close()
+}
+
+func TestListener_GracefulStop(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handled := make(chan struct{})
+	srv := NewListener(lis, func(ctx context.Context, conn net.Conn) {
+		_, _ = io.WriteString(conn, "bye")
+		close(handled)
+	})
+	go func() { _ = srv.Start(context.Background()) }()
+	<-srv.Ready()
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-handled
+	if err := srv.Stop(context.Background()); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
+
+func TestListener_Endpoints(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	want := "tcp://" + lis.Addr().String()
+	if eps := NewListener(lis, nil).Endpoints(); len(eps) != 1 || eps[0].String() != want {
+		t.Errorf("Endpoints() = %v, want [%s]", eps, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+	ulis, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ulis.Close()
+	if eps := NewListener(ulis, nil).Endpoints(); len(eps) != 1 || eps[0].String() != "unix://"+path {
+		t.Errorf("Endpoints() = %v, want [unix://%s]", eps, path)
+	}
+}
+
+func TestListener_Restart(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewListener(lis, func(ctx context.Context, conn net.Conn) {
+		_, _ = io.WriteString(conn, "hi\n")
+	})
+	for i := 0; i < 2; i++ {
+		errc := make(chan error, 1)
+		go func() { errc <- srv.Start(context.Background()) }()
+		<-srv.Ready()
+
+		// 再次启动时在同一地址上重新监听
+		var conn net.Conn
+		for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+			if conn, err = net.Dial("tcp", lis.Addr().String()); err == nil || time.Now().After(deadline) {
+				break
+			}
+		}
+		if err != nil {
+			t.Fatalf("start %d: Dial() error = %v", i, err)
+		}
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		conn.Close()
+		if line != "hi\n" {
+			t.Errorf("start %d: read %q, %v, want hi", i, line, err)
+		}
+		if err := srv.Stop(context.Background()); err != nil {
+			t.Errorf("start %d: Stop() error = %v", i, err)
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("start %d: Start() error = %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-inspire/pkg/app/server"
+)
+
+var (
+	_ server.Server  = (*Worker)(nil)
+	_ server.Readier = (*Worker)(nil)
+)
+
+// Worker 将后台任务 func(ctx) error 适配为 server.Server，
+// 任务应在 ctx 取消后尽快返回
+type Worker struct {
+	fn    func(context.Context) error
+	ready chan struct{}
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWorker 创建后台任务的适配器
+func NewWorker(fn func(context.Context) error) *Worker {
+	return &Worker{
+		fn:    fn,
+		ready: make(chan struct{}),
+	}
+}
+
+// NewTicker 创建定时任务的适配器，每隔 interval 执行一次 fn；
+// fn 返回错误时任务结束，并由 Start 返回该错误
+func NewTicker(interval time.Duration, fn func(context.Context) error) *Worker {
+	return NewWorker(func(ctx context.Context) error {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-t.C:
+				if err := fn(ctx); err != nil {
+					return err
+				}
+			}
+		}
+	})
+}
+
+// Start 执行任务直到任务返回或服务停止，因停止而返回的 context.Canceled 不视为错误
+func (w *Worker) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	w.mu.Lock()
+	w.cancel, w.done = cancel, done
+	w.mu.Unlock()
+	select {
+	case <-w.ready:
+	default:
+		close(w.ready)
+	}
+
+	if err := w.fn(ctx); err != nil && !(errors.Is(err, context.Canceled) && ctx.Err() != nil) {
+		return err
+	}
+	return nil
+}
+
+// Stop 取消任务并等待其返回；ctx 到期后不再等待，并返回 ctx 的错误
+func (w *Worker) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Ready 实现 server.Readier，任务开始执行后关闭
+func (w *Worker) Ready() <-chan struct{} {
+	return w.ready
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorker(t *testing.T) {
+	w := NewWorker(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	errc := make(chan error, 1)
+	go func() { errc <- w.Start(context.Background()) }()
+	<-w.Ready()
+	if err := w.Stop(context.Background()); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Start() error = %v, want nil after stop", err)
+	}
+}
+
+func TestWorker_Error(t *testing.T) {
+	want := errors.New("failed")
+	w := NewWorker(func(ctx context.Context) error { return want })
+	if err := w.Start(context.Background()); !errors.Is(err, want) {
+		t.Errorf("Start() error = %v, want %v", err, want)
+	}
+}
+
+func TestWorker_StopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	w := NewWorker(func(ctx context.Context) error {
+		<-release // ignores ctx
+		return nil
+	})
+	go func() { _ = w.Start(context.Background()) }()
+	<-w.Ready()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := w.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestTicker(t *testing.T) {
+	var n atomic.Int32
+	w := NewTicker(time.Millisecond, func(ctx context.Context) error {
+		n.Add(1)
+		return nil
+	})
+	errc := make(chan error, 1)
+	go func() { errc <- w.Start(context.Background()) }()
+	for n.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := w.Stop(context.Background()); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+}
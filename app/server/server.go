@@ -6,7 +6,11 @@
 
 package server
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
 
 // Server 服务接口，又 #app 统一管理
 type Server interface {
@@ -16,3 +20,56 @@ type Server interface {
 	// Stop 停止服务
 	Stop(context.Context) error
 }
+
+// Readier 可选接口，由需要较长启动过程的服务实现。
+// #app 在启动下一阶段的服务并宣告应用启动完成之前，会等待 Ready 返回的通道关闭。
+type Readier interface {
+	// Ready 返回一个通道，服务完成启动（如开始监听）后关闭该通道
+	Ready() <-chan struct{}
+}
+
+// Named 可选接口，由具有名称的服务实现，
+// #app 在日志、错误和健康检查结果中使用该名称代替服务的序号和类型标识服务。
+type Named interface {
+	// Name 返回服务名称
+	Name() string
+}
+
+// Name 返回第 i 个注册的服务的名称：实现了 Named 时为其名称，否则为序号和类型，如 #0(*adapter.HTTP)。
+// #app 的日志和错误以及健康检查结果都使用该名称
+func Name(i int, srv Server) string {
+	if n, ok := srv.(Named); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("#%d(%T)", i, srv)
+}
+
+// HealthChecker 可选接口，由能够报告自身健康状况的服务实现，
+// 健康检查服务会汇总所有已注册服务的检查结果。
+type HealthChecker interface {
+	// HealthCheck 检查服务是否健康，不健康时返回错误
+	HealthCheck(context.Context) error
+}
+
+// Reloader 可选接口，由支持重新加载配置的服务实现。
+// #app 收到 SIGHUP 信号时会调用所有实现了该接口的服务的 Reload 方法。
+type Reloader interface {
+	// Reload 重新加载服务配置
+	Reload(context.Context) error
+}
+
+// Endpointer 可选接口，由对外提供访问地址的服务实现。
+// #app 汇总所有服务的地址作为应用的 Endpoints，用于健康检查、服务注册和日志。
+type Endpointer interface {
+	// Endpoints 返回服务启动后实际监听的地址，如 http://127.0.0.1:8000、tcp://127.0.0.1:9000、unix:///tmp/app.sock，
+	// 服务未启动时返回 nil
+	Endpoints() []*url.URL
+}
+
+// Restartable 可选接口，由不一定能再次启动的服务实现，未实现该接口的服务视为可以再次启动。
+// Restartable 返回 false 的服务在 Start 返回后不能再次启动，
+// #app 拒绝为其设置重启策略，leader.Server 也不能包装它。
+type Restartable interface {
+	// Restartable 报告服务在 Start 返回后能否再次启动
+	Restartable() bool
+}
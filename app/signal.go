@@ -0,0 +1,116 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/go-inspire/pkg/app/server"
+	"github.com/go-inspire/pkg/log"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// SignalHandler handles a non-stop signal received by Run.
+type SignalHandler func(ctx context.Context, sig os.Signal)
+
+// SignalNotifier relays incoming signals to Run, os/signal by default.
+// Tests replace it to inject simulated signals.
+type SignalNotifier interface {
+	// Notify relays the signals to c, as signal.Notify.
+	Notify(c chan<- os.Signal, sigs ...os.Signal)
+	// Stop stops relaying signals to c, as signal.Stop.
+	Stop(c chan<- os.Signal)
+}
+
+// osNotifier is the SignalNotifier of the process signals.
+type osNotifier struct{}
+
+func (osNotifier) Notify(c chan<- os.Signal, sigs ...os.Signal) { signal.Notify(c, sigs...) }
+
+func (osNotifier) Stop(c chan<- os.Signal) { signal.Stop(c) }
+
+// handlers returns the signal handlers, the defaults overridden by the options.
+// Stop signals are never handled by a SignalHandler.
+func (a *App) handlers() map[os.Signal]SignalHandler {
+	result := a.defaultHandlers()
+	for sig, h := range a.opts.handlers {
+		if h == nil {
+			delete(result, sig)
+		} else {
+			result[sig] = h
+		}
+	}
+	for _, sig := range a.opts.sigs {
+		delete(result, sig)
+	}
+	return result
+}
+
+// Reload reloads the configuration, the log configuration and every
+// server implementing server.Reloader. All of them are reloaded even if
+// some fail, and the errors are joined.
+func (a *App) Reload(ctx context.Context) error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	var errs []error
+	if a.opts.config != nil {
+		errs = append(errs, a.opts.config.Reload())
+	}
+	errs = append(errs, log.Reload())
+	for i, srv := range a.opts.servers {
+		if r, ok := srv.(server.Reloader); ok {
+			if err := r.Reload(ctx); err != nil {
+				errs = append(errs, errors.Join(errors.New(server.Name(i, srv)), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reloadOnSignal is the default SIGHUP handler.
+func (a *App) reloadOnSignal(ctx context.Context, sig os.Signal) {
+	if err := a.Reload(ctx); err != nil {
+		a.logw(log.ErrorLevel, "reload failed", "signal", sig.String(), "error", err)
+		return
+	}
+	a.logw(log.InfoLevel, "reloaded", "signal", sig.String())
+}
+
+// dumpOnSignal is the default SIGUSR1 handler, it logs the App state and
+// the stacks of all goroutines.
+func (a *App) dumpOnSignal(ctx context.Context, sig os.Signal) {
+	servers := make([]string, 0, len(a.opts.servers))
+	for i, srv := range a.opts.servers {
+		state := "running"
+		if r, ok := srv.(server.Readier); ok {
+			select {
+			case <-r.Ready():
+			default:
+				state = "starting"
+			}
+		}
+		servers = append(servers, server.Name(i, srv)+": "+state)
+	}
+	stopping := false
+	select {
+	case <-ctx.Done():
+		stopping = true
+	default:
+	}
+	a.logw(log.InfoLevel, "app state", "uptime", time.Since(a.startTime).String(),
+		"stopping", stopping, "servers", servers, "goroutines", runtime.NumGoroutine())
+
+	var buf bytes.Buffer
+	_ = pprof.Lookup("goroutine").WriteTo(&buf, 2)
+	a.logw(log.InfoLevel, "goroutine dump", "stacks", buf.String())
+}
@@ -0,0 +1,153 @@
+//go:build !windows
+
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package app_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-inspire/pkg/app"
+	"github.com/go-inspire/pkg/app/apptest"
+)
+
+func TestApp_ForcedStop(t *testing.T) {
+	srv := apptest.NewServer("slow", &apptest.Recorder{})
+	srv.StopDelay = time.Minute
+	stopping := make(chan context.Context, 1)
+	h := apptest.Start(t, nil, app.Server(srv), app.Signal(syscall.SIGUSR2),
+		app.BeforeStop(func(ctx context.Context) error {
+			stopping <- ctx
+			return nil
+		}))
+	if err := h.WaitReady(time.Second); err != nil {
+		t.Fatalf("WaitReady() error = %v", err)
+	}
+
+	if !h.Signal(syscall.SIGUSR2) {
+		t.Fatal("the first SIGUSR2 was not delivered")
+	}
+	if ctx := <-stopping; ctx.Err() != nil { // graceful stop began
+		t.Error("Stop context is already cancelled")
+	}
+	if !h.Signal(syscall.SIGUSR2) {
+		t.Fatal("the second SIGUSR2 was not delivered")
+	}
+	if err := h.RequireExit(time.Second); !errors.Is(err, app.ErrForcedStop) {
+		t.Errorf("Run() error = %v, want %v", err, app.ErrForcedStop)
+	}
+}
+
+// reloadServer is a server.Reloader counting its reloads.
+type reloadServer struct {
+	*apptest.Server
+	reloaded chan struct{}
+}
+
+func (s *reloadServer) Reload(ctx context.Context) error {
+	s.reloaded <- struct{}{}
+	return nil
+}
+
+func TestApp_ReloadOnSIGHUP(t *testing.T) {
+	srv := &reloadServer{Server: apptest.NewServer("srv", &apptest.Recorder{}), reloaded: make(chan struct{}, 1)}
+	h := apptest.Start(t, nil, app.Server(srv))
+	if err := h.WaitReady(time.Second); err != nil {
+		t.Fatalf("WaitReady() error = %v", err)
+	}
+
+	if !h.Signal(syscall.SIGHUP) {
+		t.Fatal("SIGHUP was not delivered")
+	}
+	select {
+	case <-srv.reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("server was not reloaded on SIGHUP")
+	}
+	h.Stop()
+	if err := h.RequireExit(time.Second); err != nil {
+		t.Errorf("Run() error = %v, SIGHUP must not stop the app", err)
+	}
+}
+
+func TestApp_HandleSignal(t *testing.T) {
+	handled := make(chan os.Signal, 1)
+	h := apptest.Start(t, nil, app.HandleSignal(syscall.SIGUSR1, func(ctx context.Context, sig os.Signal) {
+		if _, ok := app.FromContext(ctx); !ok {
+			t.Error("handler context does not carry AppInfo")
+		}
+		handled <- sig
+	}))
+	if err := h.WaitReady(time.Second); err != nil {
+		t.Fatalf("WaitReady() error = %v", err)
+	}
+
+	if !h.Signal(syscall.SIGUSR1) {
+		t.Fatal("SIGUSR1 was not delivered")
+	}
+	select {
+	case sig := <-handled:
+		if sig != syscall.SIGUSR1 {
+			t.Errorf("signal = %v, want %v", sig, syscall.SIGUSR1)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SIGUSR1 was not handled")
+	}
+	if !h.Signal(syscall.SIGTERM) {
+		t.Fatal("SIGTERM was not delivered")
+	}
+	if err := h.RequireExit(time.Second); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+// pendingServer is a reloadServer that is never ready.
+type pendingServer struct {
+	reloadServer
+	started chan struct{}
+}
+
+func (s *pendingServer) Start(ctx context.Context) error {
+	close(s.started)
+	return s.Server.Start(ctx)
+}
+
+func TestApp_SignalWhileNotReady(t *testing.T) {
+	rec := &apptest.Recorder{}
+	srv := &pendingServer{
+		reloadServer: reloadServer{Server: apptest.NewServer("pending", rec), reloaded: make(chan struct{}, 1)},
+		started:      make(chan struct{}),
+	}
+	srv.ReadyDelay = time.Minute
+	h := apptest.Start(t, rec, app.Server(srv), app.ReadyTimeout(time.Minute))
+	select {
+	case <-srv.started:
+	case <-time.After(time.Second):
+		t.Fatal("the server was not started")
+	}
+
+	if !h.Signal(syscall.SIGHUP) {
+		t.Fatal("SIGHUP was not delivered")
+	}
+	select {
+	case <-srv.reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("server was not reloaded on SIGHUP")
+	}
+	if !h.Signal(syscall.SIGTERM) {
+		t.Fatal("SIGTERM was not delivered")
+	}
+	if err := h.RequireExit(time.Second); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	rec.AssertEvents(t, apptest.BeforeStart, "start pending", apptest.BeforeStop, "stop pending", apptest.AfterStop)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultHandlers returns the default handlers: SIGHUP reloads the app.
+func (a *App) defaultHandlers() map[os.Signal]SignalHandler {
+	return map[os.Signal]SignalHandler{
+		syscall.SIGHUP: a.reloadOnSignal,
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-inspire/pkg/app/server"
+	"github.com/go-inspire/pkg/log"
+	"time"
+)
+
+// ErrRestartExhausted is returned by Run when a supervised server failed more
+// often than its RestartPolicy allows.
+var ErrRestartExhausted = errors.New("app: restart budget exhausted")
+
+// RestartMode decides when a supervised server is restarted.
+type RestartMode int
+
+const (
+	// RestartNever never restarts the server, a failing server stops the app.
+	RestartNever RestartMode = iota
+	// RestartOnFailure restarts the server when Start returns an error.
+	RestartOnFailure
+	// RestartAlways restarts the server whenever Start returns.
+	RestartAlways
+)
+
+// RestartPolicy is the restart policy of a supervised server.
+type RestartPolicy struct {
+	// Mode decides when the server is restarted.
+	Mode RestartMode
+	// MinBackoff is the delay before the first restart, 100ms by default.
+	// It doubles after every restart up to MaxBackoff.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum delay between restarts, 30s by default.
+	MaxBackoff time.Duration
+	// MaxRestarts is the restart budget within Window, zero is unlimited.
+	// The app is stopped once the budget is exhausted.
+	MaxRestarts int
+	// Window is the period the restarts are counted in, zero counts all restarts.
+	Window time.Duration
+}
+
+// RestartEvent reports a restart of a supervised server.
+type RestartEvent struct {
+	// Server is the restarted server.
+	Server server.Server
+	// Name is the name of the server.
+	Name string
+	// Err is the error returned by Start, nil if it returned cleanly.
+	Err error
+	// Restarts is the number of restarts within the policy window, this one included.
+	Restarts int
+	// Backoff is the delay before the restart.
+	Backoff time.Duration
+	// Exhausted reports that the restart budget is exhausted and the app stops.
+	Exhausted bool
+}
+
+// supervision declares the restart policy of srv.
+type supervision struct {
+	srv    server.Server
+	policy RestartPolicy
+}
+
+// policies resolves the restart policies by server index.
+func policies(servers []server.Server, sups []supervision) (map[int]RestartPolicy, error) {
+	result := make(map[int]RestartPolicy, len(sups))
+	for _, s := range sups {
+		i := indexOf(servers, s.srv)
+		if i < 0 {
+			return nil, fmt.Errorf("app: supervised server %T is not registered", s.srv)
+		}
+		p := s.policy
+		if r, ok := s.srv.(server.Restartable); ok && !r.Restartable() && p.Mode != RestartNever {
+			return nil, fmt.Errorf("app: supervised server %s cannot be restarted", server.Name(i, s.srv))
+		}
+		if p.MinBackoff <= 0 {
+			p.MinBackoff = 100 * time.Millisecond
+		}
+		if p.MaxBackoff <= 0 {
+			p.MaxBackoff = 30 * time.Second
+		}
+		if p.MaxBackoff < p.MinBackoff {
+			p.MaxBackoff = p.MinBackoff
+		}
+		result[i] = p
+	}
+	return result, nil
+}
+
+// startServer starts the i-th server and restarts it according to its
+// RestartPolicy until the app stops or the restart budget is exhausted.
+func (a *App) startServer(ctx context.Context, i int) error {
+	srv := a.opts.servers[i]
+	policy, ok := a.policies[i]
+	if !ok || policy.Mode == RestartNever {
+		return srv.Start(ctx)
+	}
+
+	name := server.Name(i, srv)
+	backoff := policy.MinBackoff
+	var restarts []time.Time
+	for {
+		err := srv.Start(ctx)
+		if ctx.Err() != nil {
+			return err // the app is stopping
+		}
+		if err == nil && policy.Mode == RestartOnFailure {
+			return nil
+		}
+
+		now := time.Now()
+		if policy.Window > 0 {
+			for len(restarts) > 0 && now.Sub(restarts[0]) > policy.Window {
+				restarts = restarts[1:]
+			}
+			if len(restarts) == 0 {
+				backoff = policy.MinBackoff
+			}
+		}
+		if policy.MaxRestarts > 0 && len(restarts) >= policy.MaxRestarts {
+			a.notifyRestart(RestartEvent{Server: srv, Name: name, Err: err, Restarts: len(restarts), Exhausted: true})
+			if err == nil {
+				return fmt.Errorf("%w: %s", ErrRestartExhausted, name)
+			}
+			return fmt.Errorf("%w: %s: %w", ErrRestartExhausted, name, err)
+		}
+		restarts = append(restarts, now)
+		a.notifyRestart(RestartEvent{Server: srv, Name: name, Err: err, Restarts: len(restarts), Backoff: backoff})
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// notifyRestart logs the event and passes it to the OnRestart funcs.
+func (a *App) notifyRestart(e RestartEvent) {
+	if e.Exhausted {
+		a.logw(log.ErrorLevel, "server restart budget exhausted", "server", e.Name, "restarts", e.Restarts, "error", e.Err)
+	} else {
+		a.logw(log.WarnLevel, "server restarting", "server", e.Name, "restarts", e.Restarts,
+			"backoff", e.Backoff.String(), "error", e.Err)
+	}
+	for _, fn := range a.opts.onRestart {
+		fn(e)
+	}
+}
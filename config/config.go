@@ -0,0 +1,245 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+// Package config 提供类型化的配置加载，按 默认值、配置文件(JSON/YAML)、环境变量、命令行参数
+// 的顺序合并配置，支持校验和基于 fsnotify 的热加载。
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-inspire/pkg/internal/fswatch"
+)
+
+// ErrClosed 表示配置已关闭
+var ErrClosed = errors.New("config: closed")
+
+// Validator 由需要校验的配置类型实现，加载和热加载时都会调用
+type Validator interface {
+	Validate() error
+}
+
+// Source 是所有 *Config[T] 实现的非泛型接口，用于将配置挂载到 app.App
+type Source interface {
+	// Reload 重新加载配置
+	Reload() error
+	// Close 停止热加载并释放资源
+	Close() error
+}
+
+// Option 配置加载选项
+type Option func(o *options)
+
+type options struct {
+	files     []string
+	envPrefix string
+	env       bool
+	flags     *flag.FlagSet
+	args      []string
+	watch     bool
+	debounce  time.Duration
+	onError   func(error)
+}
+
+// File 添加配置文件，按扩展名(.json/.yaml/.yml)选择格式，多个文件按添加顺序合并
+func File(paths ...string) Option {
+	return func(o *options) { o.files = append(o.files, paths...) }
+}
+
+// Env 启用环境变量覆盖，变量名为 prefix 加字段路径，如 APP_SERVER_ADDR
+// 字段路径取 json 标签名或字段名，可用 env 标签覆盖，env:"-" 表示忽略该字段
+func Env(prefix string) Option {
+	return func(o *options) { o.env, o.envPrefix = true, prefix }
+}
+
+// Flags 启用命令行参数覆盖，为每个字段在 fs 上定义参数并解析 args
+// 参数名为小写的点分字段路径，如 server.addr，可用 flag 标签覆盖，usage 标签为参数说明
+// 只有命令行中显式设置的参数才会覆盖配置
+func Flags(fs *flag.FlagSet, args []string) Option {
+	return func(o *options) { o.flags, o.args = fs, args }
+}
+
+// Watch 启用配置文件热加载，文件变化后在 debounce 时间内合并多次事件再重新加载
+func Watch(debounce time.Duration) Option {
+	return func(o *options) { o.watch, o.debounce = true, debounce }
+}
+
+// OnError 设置热加载失败和监听出错时的回调，失败时保留原有配置
+func OnError(fn func(error)) Option {
+	return func(o *options) { o.onError = fn }
+}
+
+var _ Source = (*Config[struct{}])(nil)
+
+// Config 是类型为 T 的配置，可以被多个 goroutine 并发读取
+type Config[T any] struct {
+	opts     options
+	defaults T
+	flagSet  map[string]string // 命令行中显式设置的参数
+
+	value atomic.Pointer[T]
+
+	mu      sync.Mutex // 保护加载过程和订阅者
+	subs    map[int]func(*T)
+	nextSub int
+	watcher *fswatch.Watcher
+	closed  bool
+}
+
+// Load 以 defaults 为默认值加载配置
+func Load[T any](defaults T, opts ...Option) (*Config[T], error) {
+	c := &Config[T]{
+		defaults: defaults,
+		subs:     make(map[int]func(*T)),
+	}
+	for _, o := range opts {
+		o(&c.opts)
+	}
+	if c.opts.flags != nil {
+		set, err := defineFlags(c.opts.flags, c.opts.args, &c.defaults)
+		if err != nil {
+			return nil, err
+		}
+		c.flagSet = set
+	}
+	v, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	c.value.Store(v)
+
+	if c.opts.watch && len(c.opts.files) > 0 {
+		w, err := fswatch.New(c.opts.files, c.opts.debounce, c.reloadOnChange, c.watchError)
+		if err != nil {
+			return nil, err
+		}
+		c.watcher = w
+	}
+	return c, nil
+}
+
+// Get 返回当前配置，返回值是只读快照，不应修改
+func (c *Config[T]) Get() *T {
+	return c.value.Load()
+}
+
+// Reload 重新加载配置，成功后通知所有订阅者；失败时保留原有配置并返回错误
+// 订阅者在释放锁之后调用，可以在回调中订阅、取消订阅或再次调用 Reload
+func (c *Config[T]) Reload() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	v, err := c.load()
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	c.value.Store(v)
+	fns := make([]func(*T), 0, len(c.subs))
+	for _, fn := range c.subs {
+		fns = append(fns, fn)
+	}
+	c.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(v)
+	}
+	return nil
+}
+
+// Subscribe 订阅配置变化，配置重新加载成功后以新配置调用 fn，返回取消订阅的函数
+func (c *Config[T]) Subscribe(fn func(*T)) (cancel func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextSub
+	c.nextSub++
+	c.subs[id] = fn
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.subs, id)
+	}
+}
+
+// Close 停止热加载
+func (c *Config[T]) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	w := c.watcher
+	c.watcher = nil
+	c.mu.Unlock()
+	if w != nil {
+		return w.Close()
+	}
+	return nil
+}
+
+// reloadOnChange 在配置文件变化时重新加载
+func (c *Config[T]) reloadOnChange() {
+	if err := c.Reload(); err != nil && !errors.Is(err, ErrClosed) && c.opts.onError != nil {
+		c.opts.onError(err)
+	}
+}
+
+// watchError 报告监听配置文件时的错误
+func (c *Config[T]) watchError(err error) {
+	if c.opts.onError != nil {
+		c.opts.onError(fmt.Errorf("config: watch: %w", err))
+	}
+}
+
+// load 依次合并默认值、配置文件、环境变量和命令行参数，并校验结果
+func (c *Config[T]) load() (*T, error) {
+	v := new(T)
+	deepCopy(reflect.ValueOf(v).Elem(), reflect.ValueOf(&c.defaults).Elem())
+	for _, file := range c.opts.files {
+		if err := loadFile(file, v); err != nil {
+			return nil, err
+		}
+	}
+	if c.opts.env {
+		if err := applyEnv(c.opts.envPrefix, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyFlags(c.flagSet, v); err != nil {
+		return nil, err
+	}
+	if err := validate(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// validate 调用 T 或 *T 实现的 Validator
+func validate(v interface{}) error {
+	if val, ok := v.(Validator); ok {
+		return val.Validate()
+	}
+	return nil
+}
+
+type configKey struct{}
+
+// NewContext 返回携带配置的 Context
+func NewContext(ctx context.Context, s Source) context.Context {
+	return context.WithValue(ctx, configKey{}, s)
+}
+
+// FromContext 返回 ctx 中类型为 T 的配置
+func FromContext[T any](ctx context.Context) (*Config[T], bool) {
+	c, ok := ctx.Value(configKey{}).(*Config[T])
+	return c, ok
+}
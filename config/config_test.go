@@ -0,0 +1,209 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type serverConfig struct {
+	Addr    string        `json:"addr" usage:"listen address"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+type testConfig struct {
+	Name    string            `json:"name"`
+	Debug   bool              `json:"debug"`
+	Workers int               `json:"workers"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Server  serverConfig      `json:"server"`
+	Secret  string            `json:"secret" env:"-"`
+}
+
+func (c *testConfig) Validate() error {
+	if c.Workers < 0 {
+		return errors.New("workers must not be negative")
+	}
+	return nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func defaults() testConfig {
+	return testConfig{
+		Name:    "default",
+		Workers: 1,
+		Labels:  map[string]string{"zone": "a"},
+		Server:  serverConfig{Addr: ":8080", Timeout: time.Second},
+	}
+}
+
+func TestLoad_Merge(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := writeFile(t, dir, "app.json", `{"name":"json","workers":2,"labels":{"env":"prod"}}`)
+	yamlFile := writeFile(t, dir, "app.yaml", "workers: 3\nserver:\n  addr: \":9090\"\n")
+	t.Setenv("TEST_SERVER_TIMEOUT", "5s")
+	t.Setenv("TEST_TAGS", "a, b")
+	t.Setenv("TEST_SECRET", "ignored")
+
+	def := defaults()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c, err := Load(def,
+		File(jsonFile, yamlFile),
+		Env("TEST"),
+		Flags(fs, []string{"-debug=true", "-server.addr", ":7070"}),
+	)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := testConfig{
+		Name:    "json",
+		Debug:   true,
+		Workers: 3,
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"zone": "a", "env": "prod"},
+		Server:  serverConfig{Addr: ":7070", Timeout: 5 * time.Second},
+	}
+	if got := c.Get(); !reflect.DeepEqual(*got, want) {
+		t.Errorf("Get() = %+v, want %+v", *got, want)
+	}
+	if len(def.Labels) != 1 {
+		t.Errorf("defaults were modified: %v", def.Labels)
+	}
+	if f := fs.Lookup("server.addr"); f == nil || f.Usage != "listen address" {
+		t.Errorf("flag server.addr = %+v, want defined with usage", f)
+	}
+}
+
+func TestLoad_Validate(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.json", `{"workers":-1}`)
+	if _, err := Load(defaults(), File(file)); err == nil {
+		t.Error("Load() error = nil, want validation error")
+	}
+	if _, err := Load(defaults(), File(filepath.Join(dir, "app.toml"))); err == nil {
+		t.Error("Load() error = nil, want error for missing file")
+	}
+}
+
+func TestLoad_DurationString(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{
+		writeFile(t, dir, "app.json", `{"server":{"timeout":"5s"}}`),
+		writeFile(t, dir, "app.yaml", "server:\n  timeout: 5s\n"),
+	} {
+		c, err := Load(defaults(), File(file))
+		if err != nil {
+			t.Fatalf("Load(%s) error = %v", filepath.Ext(file), err)
+		}
+		if got := c.Get().Server.Timeout; got != 5*time.Second {
+			t.Errorf("Load(%s) timeout = %v, want 5s", filepath.Ext(file), got)
+		}
+	}
+	file := writeFile(t, dir, "bad.json", `{"server":{"timeout":"soon"}}`)
+	if _, err := Load(defaults(), File(file)); err == nil || !strings.Contains(err.Error(), "server.timeout") {
+		t.Errorf("Load() error = %v, want the invalid duration reported", err)
+	}
+}
+
+func TestConfig_ReloadFromSubscriber(t *testing.T) {
+	c, err := Load(defaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	var cancel func()
+	cancel = c.Subscribe(func(*testConfig) {
+		calls++
+		cancel() // 回调中取消订阅和重新加载不会死锁
+		c.Subscribe(func(*testConfig) {})
+		if calls == 1 {
+			_ = c.Reload()
+		}
+	})
+	done := make(chan error, 1)
+	go func() { done <- c.Reload() }()
+	select {
+	case err := <-done:
+		if err != nil || calls != 1 {
+			t.Errorf("Reload() = %v, calls = %d, want nil and 1", err, calls)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Reload() deadlocked")
+	}
+}
+
+func TestConfig_Watch(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.json", `{"workers":2}`)
+	errs := make(chan error, 1)
+	c, err := Load(defaults(), File(file), Watch(10*time.Millisecond), OnError(func(err error) { errs <- err }))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	defer c.Close()
+	changed := make(chan int, 1)
+	c.Subscribe(func(v *testConfig) { changed <- v.Workers })
+
+	// replace the file by rename, as editors do
+	tmp := writeFile(t, dir, "app.json.tmp", `{"workers":4}`)
+	if err := os.Rename(tmp, file); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-changed:
+		if n != 4 || c.Get().Workers != 4 {
+			t.Errorf("workers = %d, want 4", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+
+	writeFile(t, dir, "app.json", `{"workers":-1}`)
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("OnError called with nil")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("invalid config was not reported")
+	}
+	if c.Get().Workers != 4 {
+		t.Errorf("workers = %d, want the previous config kept", c.Get().Workers)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	c, err := Load(defaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext(context.Background(), c)
+	if got, ok := FromContext[testConfig](ctx); !ok || got != c {
+		t.Errorf("FromContext() = %v, %v, want the config", got, ok)
+	}
+	if _, ok := FromContext[serverConfig](ctx); ok {
+		t.Error("FromContext() with another type returned ok")
+	}
+}
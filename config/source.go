@@ -0,0 +1,450 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Unmarshal 按扩展名(.json/.yaml/.yml)解析配置数据并合并到 v
+// 所有格式统一使用 json 标签映射字段，time.Duration 字段与环境变量和命令行参数一致，
+// 也可以使用 "5s" 这样的字符串
+func Unmarshal(ext string, data []byte, v interface{}) error {
+	var m interface{}
+	switch strings.ToLower(ext) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return err
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return errors.New("invalid data after top-level value")
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		if m == nil {
+			return nil // 空文件
+		}
+	default:
+		return fmt.Errorf("config: unsupported file format %q", ext)
+	}
+	m, err := parseDurations(m, reflect.TypeOf(v), "")
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// parseDurations 将 m 中对应 time.Duration 字段的字符串解析为纳秒数，t 是 m 要合并到的类型
+func parseDurations(m interface{}, t reflect.Type, path string) (interface{}, error) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType {
+		s, ok := m.(string)
+		if !ok {
+			return m, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return int64(d), nil
+	}
+	if t == nil || isScalar(t) {
+		return m, nil
+	}
+
+	var err error
+	switch x := m.(type) {
+	case map[string]interface{}:
+		for k, v := range x {
+			var et reflect.Type
+			switch t.Kind() {
+			case reflect.Struct:
+				et = structField(t, k)
+			case reflect.Map:
+				et = t.Elem()
+			}
+			if et == nil {
+				continue
+			}
+			if x[k], err = parseDurations(v, et, joinPath(path, k)); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return m, nil
+		}
+		for i, v := range x {
+			if x[i], err = parseDurations(v, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// structField 返回结构体 t 中 json 名称为 name 的字段类型，与 encoding/json 一致，忽略大小写并展开匿名嵌入的结构体
+func structField(t reflect.Type, name string) reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && tag == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if et := structField(ft, name); et != nil {
+					return et
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = sf.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return sf.Type
+		}
+	}
+	return nil
+}
+
+// joinPath 返回点分字段路径
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// loadFile 读取配置文件并合并到 v
+func loadFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := Unmarshal(filepath.Ext(path), data, v); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// field 是配置结构体中的一个叶子字段
+type field struct {
+	path  []string // 字段路径，取 json 标签名或字段名
+	env   string   // env 标签
+	flag  string   // flag 标签
+	usage string   // usage 标签
+	index []int    // reflect 字段索引路径
+}
+
+// fields 返回类型 t 的所有叶子字段，嵌套结构体会被展开
+func fields(t reflect.Type, path []string, index []int) []field {
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var result []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := sf.Name
+		tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		p := append(append([]string(nil), path...), name)
+		idx := append(append([]int(nil), index...), i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Struct && !isScalar(ft) {
+			if sf.Anonymous && tag == "" {
+				p = path // 与 encoding/json 一致，展开匿名嵌入的结构体
+			}
+			result = append(result, fields(ft, p, idx)...)
+			continue
+		}
+		if !isLeaf(ft) {
+			continue
+		}
+		result = append(result, field{
+			path:  p,
+			env:   sf.Tag.Get("env"),
+			flag:  sf.Tag.Get("flag"),
+			usage: sf.Tag.Get("usage"),
+			index: idx,
+		})
+	}
+	return result
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isScalar 判断类型是否可以从单个字符串解析
+func isScalar(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// isLeaf 判断类型是否可以由 setString 赋值
+func isLeaf(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if isScalar(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface, reflect.Chan, reflect.Func, reflect.Pointer:
+		return false
+	case reflect.Slice, reflect.Array:
+		return t.Kind() == reflect.Slice && isLeaf(t.Elem())
+	default:
+		return true
+	}
+}
+
+// envName 返回字段对应的环境变量名
+func (f field) envName(prefix string) string {
+	if f.env == "-" {
+		return ""
+	}
+	name := f.env
+	if name == "" {
+		name = strings.ToUpper(strings.Join(f.path, "_"))
+	}
+	if prefix != "" {
+		name = prefix + "_" + name
+	}
+	return name
+}
+
+// flagName 返回字段对应的命令行参数名
+func (f field) flagName() string {
+	if f.flag != "" {
+		return f.flag
+	}
+	return strings.ToLower(strings.Join(f.path, "."))
+}
+
+// applyEnv 使用环境变量覆盖 v 的字段
+func applyEnv(prefix string, v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	for _, f := range fields(rv.Type(), nil, nil) {
+		name := f.envName(prefix)
+		if name == "" {
+			continue
+		}
+		if s, ok := os.LookupEnv(name); ok {
+			if err := setString(rv.FieldByIndex(f.index), s); err != nil {
+				return fmt.Errorf("config: env %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// flagValue 记录命令行中显式设置的参数值
+type flagValue struct {
+	def string
+	set map[string]string
+	key string
+}
+
+func (fv *flagValue) String() string {
+	if fv == nil {
+		return ""
+	}
+	if s, ok := fv.set[fv.key]; ok {
+		return s
+	}
+	return fv.def
+}
+
+func (fv *flagValue) Set(s string) error {
+	fv.set[fv.key] = s
+	return nil
+}
+
+// defineFlags 为 defaults 的字段在 fs 上定义参数并解析 args，返回显式设置的参数
+func defineFlags(fs *flag.FlagSet, args []string, defaults interface{}) (map[string]string, error) {
+	set := make(map[string]string)
+	rv := reflect.ValueOf(defaults).Elem()
+	for _, f := range fields(rv.Type(), nil, nil) {
+		if f.flag == "-" {
+			continue
+		}
+		name := f.flagName()
+		def := ""
+		if fv := reflect.Indirect(rv.FieldByIndex(f.index)); fv.IsValid() {
+			def = fmt.Sprint(fv.Interface())
+		}
+		fs.Var(&flagValue{def: def, set: set, key: name}, name, f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// applyFlags 使用显式设置的命令行参数覆盖 v 的字段
+func applyFlags(set map[string]string, v interface{}) error {
+	if len(set) == 0 {
+		return nil
+	}
+	rv := reflect.ValueOf(v).Elem()
+	for _, f := range fields(rv.Type(), nil, nil) {
+		name := f.flagName()
+		if s, ok := set[name]; ok {
+			if err := setString(rv.FieldByIndex(f.index), s); err != nil {
+				return fmt.Errorf("config: flag -%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setString 将字符串解析为 v 的类型并赋值
+// 支持 TextUnmarshaler、字符串、布尔、整数、浮点数、time.Duration 以及逗号分隔的切片
+func setString(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setString(v.Elem(), s)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		if s == "" {
+			parts = nil
+		}
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setString(slice.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// deepCopy 将 src 深拷贝到 dst，避免合并配置时修改默认值中的 map 和切片
+func deepCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		deepCopy(dst.Elem(), src.Elem())
+	case reflect.Struct:
+		dst.Set(src) // 先整体复制，包括未导出字段
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopy(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			val := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(val, iter.Value())
+			dst.SetMapIndex(iter.Key(), val)
+		}
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+// Package fswatch 基于 fsnotify 监听文件的变化，供 config 和 log 热加载配置文件使用。
+package fswatch
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher 监听文件所在的目录，以便编辑器通过重命名替换文件时也能收到事件
+type Watcher struct {
+	w        *fsnotify.Watcher
+	files    map[string]struct{}
+	debounce time.Duration
+	onChange func()
+	onError  func(error)
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New 监听 files 的变化，在 debounce 时间内的多次变化只触发一次 onChange，监听出错时调用 onError。
+// 文件被删除时不触发 onChange，等待新文件创建
+func New(files []string, debounce time.Duration, onChange func(), onError func(error)) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		w:        fw,
+		files:    make(map[string]struct{}),
+		debounce: debounce,
+		onChange: onChange,
+		onError:  onError,
+		done:     make(chan struct{}),
+	}
+	dirs := make(map[string]struct{})
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			_ = fw.Close()
+			return nil, err
+		}
+		w.files[abs] = struct{}{}
+		dirs[filepath.Dir(abs)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := fw.Add(dir); err != nil {
+			_ = fw.Close()
+			return nil, err
+		}
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case event, ok := <-w.w.Events:
+			if !ok {
+				return
+			}
+			abs, err := filepath.Abs(event.Name)
+			if err != nil {
+				continue
+			}
+			if _, ok := w.files[abs]; !ok || event.Op == fsnotify.Chmod || event.Op == fsnotify.Remove {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(w.debounce)
+			} else {
+				timer.Reset(w.debounce)
+			}
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			w.onChange()
+		case err, ok := <-w.w.Errors:
+			if !ok {
+				return
+			}
+			w.onError(err)
+		}
+	}
+}
+
+// Close 停止监听并等待监听协程退出
+func (w *Watcher) Close() error {
+	close(w.done)
+	err := w.w.Close()
+	w.wg.Wait()
+	return err
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.json")
+	other := filepath.Join(dir, "other.json")
+	changed := make(chan struct{}, 10)
+	w, err := New([]string{file}, 20*time.Millisecond, func() { changed <- struct{}{} }, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// 多次写入合并为一次变化，其他文件的变化被忽略
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(file, []byte{'0' + byte(i)}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(other, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change reported")
+	}
+
+	// 删除文件不触发变化，通过重命名创建的新文件触发
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+		t.Fatal("change reported for the removal or the debounced writes")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := os.Rename(other, file); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no change reported for the renamed file")
+	}
+}
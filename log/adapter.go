@@ -21,9 +21,14 @@ type Option func(*Adapter)
 // Adapter 提供了一个高级的日志记录接口，支持不同级别和格式的日志。
 // 它封装了一个 Logger 实现，并提供了便捷的方法来记录不同级别的日志。
 type Adapter struct {
-	logger Logger          // 底层的日志记录器
-	lvl    atomic.Int32    // 原子操作的日志级别
-	ctx    context.Context // 默认上下文
+	logger Logger                     // 底层的日志记录器，src 不为 nil 时不使用
+	src    *source                    // 可替换的底层日志记录器，通过 Named 创建的适配器及其派生适配器使用
+	kv     []interface{}              // 绑定到 src 的键值对
+	bound  atomic.Pointer[boundCache] // src 的当前值绑定 kv 后的缓存
+	name   string                     // 组件名称，通过 Named 创建时设置
+	lvl    *atomic.Int32              // 原子操作的日志级别，与通过 With 派生的适配器共享
+	drop   *dropper                   // 采样和限流，与通过 With 派生的适配器共享
+	ctx    context.Context            // 默认上下文
 }
 
 // WithLevel 返回一个 Option，用于设置 Adapter 的最低启用日志级别。
@@ -47,6 +52,8 @@ func WithContext(ctx context.Context) Option {
 func NewAdapter(logger Logger, opts ...Option) *Adapter {
 	la := &Adapter{
 		logger: logger,
+		lvl:    new(atomic.Int32),
+		drop:   new(dropper),
 		ctx:    context.Background(),
 	}
 	la.SetLevel(InfoLevel)
@@ -72,6 +79,65 @@ func (la *Adapter) SetLevel(l Level) *Adapter {
 	return la
 }
 
+// With 返回一个新的适配器，它记录的每条日志都在其他键值对之前带有 keyvals。
+// 新适配器与原适配器共享日志级别；底层日志记录器支持时（如 zap 和 slog），
+// keyvals 只在派生时编码一次。
+func (la *Adapter) With(keyvals ...interface{}) *Adapter {
+	if len(keyvals) == 0 {
+		return la
+	}
+	if la.src != nil {
+		kv := make([]interface{}, 0, len(la.kv)+len(keyvals))
+		return &Adapter{
+			src:  la.src,
+			kv:   append(append(kv, la.kv...), keyvals...),
+			name: la.name,
+			lvl:  la.lvl,
+			drop: la.drop,
+			ctx:  la.ctx,
+		}
+	}
+	return &Adapter{
+		logger: bind(la.logger, keyvals...),
+		name:   la.name,
+		lvl:    la.lvl,
+		drop:   la.drop,
+		ctx:    la.ctx,
+	}
+}
+
+// Name 返回通过 Named 创建的适配器的组件名称，其他适配器返回空字符串。
+func (la *Adapter) Name() string {
+	return la.name
+}
+
+// Named 返回组件层级中名为 s 的子组件的日志适配器，如 Named("a").Named("b") 等同于 Named("a.b")。
+// 适配器不是通过 Named 创建时，等同于全局的 Named(s)。
+func (la *Adapter) Named(s string) *Adapter {
+	if la.name == "" {
+		return Named(s)
+	}
+	return Named(la.name + "." + s)
+}
+
+// backend 返回当前的底层日志记录器。
+// 使用可替换的日志记录器时，返回其当前值绑定键值对后的日志记录器，并缓存到替换为止。
+func (la *Adapter) backend() Logger {
+	if la.src == nil {
+		return la.logger
+	}
+	cur := la.src.load()
+	if b := la.bound.Load(); b != nil && b.src == cur {
+		return b.logger
+	}
+	b := &boundCache{src: cur, logger: cur.Logger}
+	if len(la.kv) > 0 {
+		b.logger = bind(cur.Logger, la.kv...)
+	}
+	la.bound.Store(b)
+	return b.logger
+}
+
 // Enabled 实现了 zapcore.LevelEnabler 接口。
 // 如果给定的日志级别已启用，则返回 true。
 func (la *Adapter) Enabled(l Level) bool {
@@ -81,8 +147,8 @@ func (la *Adapter) Enabled(l Level) bool {
 // logWithLevel 是一个通用的日志记录方法，处理所有日志级别。
 // 它格式化消息并在级别启用时调用底层日志记录器。
 func (la *Adapter) logWithLevel(level Level, msg string, args ...interface{}) {
-	la.output(la.ctx, level, func(ctx context.Context, l Level) {
-		la.logger.Log(ctx, l, msg, args...)
+	la.output(la.ctx, level, msg, func(ctx context.Context, l Level) {
+		la.backend().Log(ctx, l, msg, args...)
 	})
 }
 
@@ -98,6 +164,21 @@ func (la *Adapter) logWithKeyValues(level Level, keyvals ...interface{}) {
 	la.logWithLevel(level, "", keyvals...)
 }
 
+// logWithLevelCtx 与 logWithLevel 相同，但使用给定的上下文，ctx 为 nil 时使用默认上下文。
+func (la *Adapter) logWithLevelCtx(ctx context.Context, level Level, msg string, args ...interface{}) {
+	if ctx == nil {
+		ctx = la.ctx
+	}
+	la.output(ctx, level, msg, func(ctx context.Context, l Level) {
+		la.backend().Log(ctx, l, msg, args...)
+	})
+}
+
+// logWithFormatCtx 与 logWithFormat 相同，但使用给定的上下文。
+func (la *Adapter) logWithFormatCtx(ctx context.Context, level Level, format string, args ...interface{}) {
+	la.logWithLevelCtx(ctx, level, sprintf(format, args...))
+}
+
 // Print 系列方法在 Info 级别记录日志。
 // Print 记录一个简单的消息。
 func (la *Adapter) Print(args ...interface{}) {
@@ -178,6 +259,66 @@ func (la *Adapter) Errorw(keyvals ...interface{}) {
 	la.logWithKeyValues(ErrorLevel, keyvals...)
 }
 
+// DebugCtx 使用 ctx 在 Debug 级别记录一个简单的消息，ctx 中通过 WithFields 附加的键值对会一并输出。
+func (la *Adapter) DebugCtx(ctx context.Context, args ...interface{}) {
+	la.logWithLevelCtx(ctx, DebugLevel, sprint(args...))
+}
+
+// DebugfCtx 使用 ctx 在 Debug 级别记录格式化的消息。
+func (la *Adapter) DebugfCtx(ctx context.Context, msg string, args ...interface{}) {
+	la.logWithFormatCtx(ctx, DebugLevel, msg, args...)
+}
+
+// DebugwCtx 使用 ctx 在 Debug 级别记录键值对。
+func (la *Adapter) DebugwCtx(ctx context.Context, keyvals ...interface{}) {
+	la.logWithLevelCtx(ctx, DebugLevel, "", keyvals...)
+}
+
+// InfoCtx 使用 ctx 在 Info 级别记录一个简单的消息，ctx 中通过 WithFields 附加的键值对会一并输出。
+func (la *Adapter) InfoCtx(ctx context.Context, args ...interface{}) {
+	la.logWithLevelCtx(ctx, InfoLevel, sprint(args...))
+}
+
+// InfofCtx 使用 ctx 在 Info 级别记录格式化的消息。
+func (la *Adapter) InfofCtx(ctx context.Context, msg string, args ...interface{}) {
+	la.logWithFormatCtx(ctx, InfoLevel, msg, args...)
+}
+
+// InfowCtx 使用 ctx 在 Info 级别记录键值对。
+func (la *Adapter) InfowCtx(ctx context.Context, keyvals ...interface{}) {
+	la.logWithLevelCtx(ctx, InfoLevel, "", keyvals...)
+}
+
+// WarnCtx 使用 ctx 在 Warn 级别记录一个简单的消息，ctx 中通过 WithFields 附加的键值对会一并输出。
+func (la *Adapter) WarnCtx(ctx context.Context, args ...interface{}) {
+	la.logWithLevelCtx(ctx, WarnLevel, sprint(args...))
+}
+
+// WarnfCtx 使用 ctx 在 Warn 级别记录格式化的消息。
+func (la *Adapter) WarnfCtx(ctx context.Context, msg string, args ...interface{}) {
+	la.logWithFormatCtx(ctx, WarnLevel, msg, args...)
+}
+
+// WarnwCtx 使用 ctx 在 Warn 级别记录键值对。
+func (la *Adapter) WarnwCtx(ctx context.Context, keyvals ...interface{}) {
+	la.logWithLevelCtx(ctx, WarnLevel, "", keyvals...)
+}
+
+// ErrorCtx 使用 ctx 在 Error 级别记录一个简单的消息，ctx 中通过 WithFields 附加的键值对会一并输出。
+func (la *Adapter) ErrorCtx(ctx context.Context, args ...interface{}) {
+	la.logWithLevelCtx(ctx, ErrorLevel, sprint(args...))
+}
+
+// ErrorfCtx 使用 ctx 在 Error 级别记录格式化的消息。
+func (la *Adapter) ErrorfCtx(ctx context.Context, msg string, args ...interface{}) {
+	la.logWithFormatCtx(ctx, ErrorLevel, msg, args...)
+}
+
+// ErrorwCtx 使用 ctx 在 Error 级别记录键值对。
+func (la *Adapter) ErrorwCtx(ctx context.Context, keyvals ...interface{}) {
+	la.logWithLevelCtx(ctx, ErrorLevel, "", keyvals...)
+}
+
 // Panic 系列方法在 Panic 级别记录日志，然后触发 panic。
 // Panic 记录一个简单的消息，然后使用相同的消息触发 panic。
 func (la *Adapter) Panic(args ...interface{}) {
@@ -225,15 +366,18 @@ func (la *Adapter) Fatalw(keyvals ...interface{}) {
 }
 
 // Flush 刷新所有缓冲的日志条目。
-// 它调用底层日志记录器的 Close() 方法。
+// 底层日志记录器实现了 Flush() 方法时（如 AsyncLogger）调用该方法，否则调用 Close() 方法。
 func (la *Adapter) Flush() error {
+	if f, ok := la.backend().(flusher); ok {
+		return f.Flush()
+	}
 	return la.Close()
 }
 
 // Close 关闭底层的日志记录器。
 // 当不再需要日志记录器时应该调用此方法。
 func (la *Adapter) Close() error {
-	return la.logger.Close()
+	return la.backend().Close()
 }
 
 // Log 使用指定的上下文在指定的级别记录消息。
@@ -242,13 +386,85 @@ func (la *Adapter) Log(ctx context.Context, level Level, msg string, keyValues .
 	if ctx == nil {
 		ctx = la.ctx
 	}
-	la.logger.Log(ctx, level, msg, keyValues...)
+	la.backend().Log(ctx, level, msg, keyValues...)
 }
 
-// output 处理实际的日志输出，并进行级别检查。
-// 只有在级别启用时才调用提供的日志函数。
-func (la *Adapter) output(ctx context.Context, l Level, log func(ctx context.Context, level Level)) {
-	if la.Enabled(l) {
+// output 处理实际的日志输出，并进行级别检查、采样和限流。
+// 只有在级别启用且未被丢弃时才调用提供的日志函数。
+func (la *Adapter) output(ctx context.Context, l Level, msg string, log func(ctx context.Context, level Level)) {
+	if la.Enabled(l) && la.drop.allow(l, msg) {
 		log(ctx, l)
 	}
 }
+
+// withLogger 由能够预先编码绑定键值对的 Logger 实现
+type withLogger interface {
+	// With 返回一个新的 Logger，它记录的每条日志都带有 keyvals
+	With(keyvals ...interface{}) Logger
+}
+
+// flusher 由需要区分刷新和关闭的 Logger 实现
+type flusher interface {
+	Flush() error
+}
+
+// source 是可以原子替换的日志记录器
+type source struct {
+	p atomic.Pointer[sourceLogger]
+}
+
+// sourceLogger 包装 source 的一个值，替换后的值是不同的指针
+type sourceLogger struct {
+	Logger
+}
+
+func (s *source) load() *sourceLogger {
+	return s.p.Load()
+}
+
+// swap 替换日志记录器并返回原有的日志记录器，原来没有时返回 nil
+func (s *source) swap(logger Logger) Logger {
+	if old := s.p.Swap(&sourceLogger{Logger: logger}); old != nil {
+		return old.Logger
+	}
+	return nil
+}
+
+// boundCache 缓存 source 的一个值绑定键值对后的日志记录器
+type boundCache struct {
+	src    *sourceLogger
+	logger Logger
+}
+
+// bind 返回一个记录的每条日志都带有 keyvals 的 Logger
+func bind(logger Logger, keyvals ...interface{}) Logger {
+	if w, ok := logger.(withLogger); ok {
+		return w.With(keyvals...)
+	}
+	return &boundLogger{Logger: logger, keyvals: keyvals}
+}
+
+// boundLogger 在每次记录日志时将绑定的键值对放在其他键值对之前，
+// 用于不支持 With 的 Logger
+type boundLogger struct {
+	Logger
+	keyvals []interface{}
+}
+
+func (l *boundLogger) Log(ctx context.Context, level Level, msg string, keyValues ...interface{}) {
+	kv := make([]interface{}, 0, len(l.keyvals)+len(keyValues))
+	kv = append(append(kv, l.keyvals...), keyValues...)
+	l.Logger.Log(ctx, level, msg, kv...)
+}
+
+func (l *boundLogger) With(keyvals ...interface{}) Logger {
+	kv := make([]interface{}, 0, len(l.keyvals)+len(keyvals))
+	return &boundLogger{Logger: l.Logger, keyvals: append(append(kv, l.keyvals...), keyvals...)}
+}
+
+func (l *boundLogger) Flush() error {
+	if f, ok := l.Logger.(flusher); ok {
+		return f.Flush()
+	}
+	return l.Logger.Close()
+}
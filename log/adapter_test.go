@@ -7,12 +7,14 @@
 package log
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -843,3 +845,33 @@ func (l *mockLogger) Log(ctx context.Context, level Level, msg string, keyvals .
 func (l *mockLogger) Close() error {
 	return l.closeError
 }
+
+func TestAdapter_With(t *testing.T) {
+	logger := &mockLogger{}
+	parent := NewAdapter(logger, WithLevel(InfoLevel))
+	child := parent.With("component", "db").With("conn", 1)
+
+	child.Infow("k", "v")
+	want := []interface{}{"component", "db", "conn", 1, "k", "v"}
+	if !reflect.DeepEqual(logger.lastKeyvals, want) {
+		t.Errorf("keyvals = %v, want %v", logger.lastKeyvals, want)
+	}
+
+	parent.SetLevel(ErrorLevel)
+	if child.Enabled(InfoLevel) {
+		t.Error("child does not share the level of its parent")
+	}
+	parent.Infow("k", "v2")
+	if logger.lastKeyvals[len(logger.lastKeyvals)-1] != "v" {
+		t.Error("the parent logged although its level is Error")
+	}
+}
+
+func TestAdapter_With_Slog(t *testing.T) {
+	var buf bytes.Buffer
+	la := NewAdapter(NewSlogLogger(&buf, InfoLevel)).With("tenant", "t1")
+	la.InfowCtx(WithFields(context.Background(), "request_id", "r1"), "k", "v")
+	if got := buf.String(); !strings.Contains(got, "tenant=t1 request_id=r1 k=v") {
+		t.Errorf("output = %q, want the bound fields first", got)
+	}
+}
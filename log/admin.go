@@ -0,0 +1,235 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LoggerInfo 描述一个组件日志适配器的状态
+type LoggerInfo struct {
+	Name    string    `json:"name"`    // 组件名称
+	Level   Level     `json:"level"`   // 当前生效的日志级别
+	Dropped DropStats `json:"dropped"` // 因采样和限流丢弃的日志条数
+}
+
+// LevelOverride 描述一个通过 SetNamedLevel 设置的临时日志级别
+type LevelOverride struct {
+	Name    string    `json:"name"`    // 组件名称或前缀，为空表示默认级别
+	Level   Level     `json:"level"`   // 临时日志级别
+	Expires time.Time `json:"expires"` // 到期时间，到期后恢复为设置前的配置
+}
+
+// levelOverride 记录临时日志级别到期后要恢复的配置
+type levelOverride struct {
+	LevelOverride
+	prev    Level // 设置前的级别
+	hadPrev bool  // 设置前是否单独配置了该组件
+	timer   *time.Timer
+}
+
+// 临时日志级别，由 mu 保护
+var overrides = make(map[string]*levelOverride)
+
+// GetConfig 返回当前的全局日志配置
+func GetConfig() Config {
+	mu.Lock()
+	defer mu.Unlock()
+	c := cfg
+	c.Named = maps.Clone(cfg.Named)
+	c.RateLimits = maps.Clone(cfg.RateLimits)
+	return c
+}
+
+// Loggers 返回所有通过 Named 创建的组件日志适配器的状态，按名称排序
+func Loggers() []LoggerInfo {
+	mu.Lock()
+	defer mu.Unlock()
+
+	result := make([]LoggerInfo, 0, len(adapters))
+	for k, a := range adapters {
+		result = append(result, LoggerInfo{Name: k, Level: a.Level(), Dropped: a.Dropped()})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Overrides 返回尚未到期的临时日志级别，按名称排序
+func Overrides() []LevelOverride {
+	mu.Lock()
+	defer mu.Unlock()
+
+	result := make([]LevelOverride, 0, len(overrides))
+	for _, o := range overrides {
+		result = append(result, o.LevelOverride)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// SetNamedLevel 在全局配置中设置组件 name 的日志级别，name 为空时设置默认级别。
+// 与 findConfigLevel 的查找规则一致，该级别同时作用于没有单独配置的子组件，如设置"a"会影响"a.b"。
+// ttl 大于 0 时，到期后恢复为设置前的配置；SetConfig 会取消所有未到期的临时级别。
+func SetNamedLevel(name string, lvl Level, ttl time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	name = strings.ToLower(name)
+	prev, hadPrev := configLevel(name)
+	if old, ok := overrides[name]; ok {
+		old.timer.Stop()
+		delete(overrides, name)
+		prev, hadPrev = old.prev, old.hadPrev // 到期后恢复最初的配置
+	}
+	setConfigLevel(name, lvl, true)
+	if ttl <= 0 {
+		return
+	}
+
+	o := &levelOverride{
+		LevelOverride: LevelOverride{Name: name, Level: lvl, Expires: time.Now().Add(ttl)},
+		prev:          prev,
+		hadPrev:       hadPrev,
+	}
+	o.timer = time.AfterFunc(ttl, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if overrides[name] == o {
+			delete(overrides, name)
+			setConfigLevel(name, o.prev, o.hadPrev)
+		}
+	})
+	overrides[name] = o
+}
+
+// configLevel 返回全局配置中组件 name 单独配置的级别，调用方需持有 mu
+func configLevel(name string) (Level, bool) {
+	if name == "" {
+		return cfg.DefaultLevel, true
+	}
+	lvl, ok := cfg.Named[name]
+	return lvl, ok
+}
+
+// setConfigLevel 设置或删除全局配置中组件 name 的级别并刷新所有适配器，调用方需持有 mu。
+// 配置中的 map 可能与调用方共享，因此修改前先复制。
+func setConfigLevel(name string, lvl Level, set bool) {
+	if name == "" {
+		cfg.DefaultLevel = lvl
+		if defaultAdapter != nil {
+			defaultAdapter.SetLevel(lvl)
+		}
+	} else {
+		named := maps.Clone(cfg.Named)
+		if named == nil {
+			named = make(map[string]Level)
+		}
+		if set {
+			named[name] = lvl
+		} else {
+			delete(named, name)
+		}
+		cfg.Named = named
+	}
+	for k, a := range adapters {
+		a.SetLevel(findConfigLevel(&cfg, k))
+	}
+}
+
+// stopOverrides 取消所有未到期的临时级别，调用方需持有 mu
+func stopOverrides() {
+	for k, o := range overrides {
+		o.timer.Stop()
+		delete(overrides, k)
+	}
+}
+
+// LevelsResponse 是 Handler 返回的日志级别状态
+type LevelsResponse struct {
+	Config    Config          `json:"config"`
+	Loggers   []LoggerInfo    `json:"loggers"`
+	Overrides []LevelOverride `json:"overrides"`
+}
+
+// LevelRequest 是 Handler 接受的 PUT 请求体
+type LevelRequest struct {
+	Name  string   `json:"name"`          // 组件名称或前缀，为空表示默认级别
+	Level string   `json:"level"`         // 日志级别(debug/info/warn/error/panic/fatal)，inherit 表示继承上级组件的级别
+	TTL   Duration `json:"ttl,omitempty"` // 临时级别的有效期，如"5m"，为空时永久生效
+}
+
+// LevelInherit 是 LevelRequest 中表示删除单独配置、继承上级组件级别的级别名称
+const LevelInherit = "inherit"
+
+// applyLevelRequest 校验并执行 PUT 请求
+func applyLevelRequest(req LevelRequest) error {
+	if req.TTL < 0 {
+		return errors.New("negative ttl")
+	}
+	switch req.Level {
+	case "":
+		return errors.New("missing level")
+	case LevelInherit:
+		if req.Name == "" {
+			return errors.New("the default level cannot inherit")
+		}
+		if req.TTL != 0 {
+			return errors.New("ttl is not supported for inherit")
+		}
+		ResetLevel(req.Name)
+		return nil
+	}
+	var lvl Level
+	if err := lvl.UnmarshalText([]byte(req.Level)); err != nil {
+		return err
+	}
+	SetNamedLevel(req.Name, lvl, time.Duration(req.TTL))
+	return nil
+}
+
+// Handler 返回管理日志级别的 http.Handler。
+// GET 返回全局配置、所有组件适配器的当前级别和未到期的临时级别；
+// PUT 按 LevelRequest 设置组件或前缀的级别，返回设置后的状态。
+func Handler() http.Handler {
+	return http.HandlerFunc(serveLevels)
+}
+
+func serveLevels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		var req LevelRequest
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := applyLevelRequest(req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(LevelsResponse{
+		Config:    GetConfig(),
+		Loggers:   Loggers(),
+		Overrides: Overrides(),
+	})
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(t *testing.T, method, body string) (int, LevelsResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(method, "/loggers", strings.NewReader(body)))
+	var resp LevelsResponse
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s decode error = %v", method, err)
+		}
+	}
+	return rec.Code, resp
+}
+
+func TestHandler(t *testing.T) {
+	saved := GetConfig()
+	defer SetConfig(saved)
+	SetConfig(Config{DefaultLevel: InfoLevel, Named: map[string]Level{"svc.db": ErrorLevel}})
+	db, cache := Named("svc.db"), Named("svc.cache")
+
+	code, resp := serve(t, http.MethodGet, "")
+	if code != http.StatusOK || resp.Config.Named["svc.db"] != ErrorLevel {
+		t.Fatalf("GET = %d %+v, want the config", code, resp.Config)
+	}
+	found := false
+	for _, l := range resp.Loggers {
+		found = found || l.Name == "svc.cache" && l.Level == InfoLevel
+	}
+	if !found {
+		t.Errorf("GET loggers = %+v, want svc.cache at info", resp.Loggers)
+	}
+
+	// 前缀级别作用于没有单独配置的子组件
+	code, resp = serve(t, http.MethodPut, `{"name":"svc","level":"debug","ttl":"50ms"}`)
+	if code != http.StatusOK || len(resp.Overrides) != 1 || resp.Overrides[0].Name != "svc" {
+		t.Fatalf("PUT = %d %+v, want the override reported", code, resp.Overrides)
+	}
+	if cache.Level() != DebugLevel || db.Level() != ErrorLevel {
+		t.Errorf("levels = %v, %v, want debug and error", cache.Level(), db.Level())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cache.Level() != InfoLevel && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if cache.Level() != InfoLevel {
+		t.Errorf("level after ttl = %v, want info", cache.Level())
+	}
+	if _, resp = serve(t, http.MethodGet, ""); len(resp.Overrides) != 0 || len(resp.Config.Named) != 1 {
+		t.Errorf("GET after ttl = %+v, want the previous config", resp)
+	}
+
+	serve(t, http.MethodPut, `{"name":"svc.db","level":"warn"}`)
+	if db.Level() != WarnLevel {
+		t.Errorf("level = %v, want warn", db.Level())
+	}
+	serve(t, http.MethodPut, `{"name":"svc.db","level":"inherit"}`)
+	if db.Level() != InfoLevel {
+		t.Errorf("level = %v, want inherited info", db.Level())
+	}
+	for _, body := range []string{`{"name":"svc"}`, `{"name":"svc","level":"loud"}`, `{"level":"info","ttl":"-1s"}`, `{"lvl":"info"}`, `{"level":"inherit"}`} {
+		if code, _ := serve(t, http.MethodPut, body); code != http.StatusBadRequest {
+			t.Errorf("PUT %s = %d, want %d", body, code, http.StatusBadRequest)
+		}
+	}
+	if code, _ := serve(t, http.MethodPost, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+}
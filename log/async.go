@@ -0,0 +1,185 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-inspire/pkg/ringbuffer"
+)
+
+// DropPolicy 定义异步日志队列已满时的处理策略
+type DropPolicy int
+
+const (
+	// Block 阻塞调用方直到队列有空位，不丢弃日志
+	Block DropPolicy = iota
+	// DropNewest 丢弃新的日志
+	DropNewest
+	// DropOldest 丢弃队列中最旧的日志
+	DropOldest
+)
+
+// AsyncOption 是一个函数类型，用于配置 AsyncLogger
+type AsyncOption func(*AsyncLogger)
+
+// WithQueueSize 返回一个 AsyncOption，用于设置队列容量，默认为 1024
+func WithQueueSize(size int) AsyncOption {
+	return func(l *AsyncLogger) {
+		if size > 0 {
+			l.size = size
+		}
+	}
+}
+
+// WithDropPolicy 返回一个 AsyncOption，用于设置队列已满时的处理策略，默认为 Block
+func WithDropPolicy(p DropPolicy) AsyncOption {
+	return func(l *AsyncLogger) {
+		l.policy = p
+	}
+}
+
+var _ Logger = (*AsyncLogger)(nil)
+
+// AsyncLogger 是异步写入的 Logger。
+// 日志记录先放入有界的环形队列，再由后台协程写入被包装的 Logger，调用方无需等待 I/O。
+// Panic 和 Fatal 级别的日志会先写完队列中的日志，再在调用方协程中同步写入。
+// 由于写入发生在后台协程，zap 记录的调用位置不再准确。
+type AsyncLogger struct {
+	logger  Logger
+	size    int
+	policy  DropPolicy
+	dropped atomic.Uint64
+
+	mu      sync.Mutex
+	cond    *sync.Cond // 队列状态变化时广播
+	queue   *ringbuffer.RingBuffer
+	writing bool // 后台协程正在写入取出的日志
+	closed  bool
+	done    chan struct{}
+}
+
+// record 是队列中的一条日志
+type record struct {
+	ctx     context.Context
+	level   Level
+	msg     string
+	keyvals []interface{}
+}
+
+// NewAsyncLogger 创建包装 logger 的异步日志记录器并启动后台写入协程。
+// 使用完毕后应调用 Close 写完队列中的日志。
+func NewAsyncLogger(logger Logger, opts ...AsyncOption) *AsyncLogger {
+	l := &AsyncLogger{
+		logger: logger,
+		size:   1024,
+		done:   make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(l)
+	}
+	l.cond = sync.NewCond(&l.mu)
+	l.queue, _ = ringbuffer.NewRingBuffer(l.size)
+	go l.run()
+	return l
+}
+
+// Log 将日志放入队列，队列已满时按 DropPolicy 处理。
+// Close 之后的日志直接同步写入。
+func (l *AsyncLogger) Log(ctx context.Context, level Level, msg string, keyValues ...interface{}) {
+	if level > ErrorLevel {
+		l.drain()
+		l.logger.Log(ctx, level, msg, keyValues...)
+		return
+	}
+
+	l.mu.Lock()
+	for !l.closed && l.queue.IsFull() {
+		if l.policy == DropNewest {
+			l.mu.Unlock()
+			l.dropped.Add(1)
+			return
+		}
+		if l.policy == DropOldest {
+			l.dropped.Add(1) // Push 覆盖最旧的日志
+			break
+		}
+		l.cond.Wait()
+	}
+	if l.closed {
+		l.mu.Unlock()
+		l.logger.Log(ctx, level, msg, keyValues...)
+		return
+	}
+	l.queue.Push(record{ctx: ctx, level: level, msg: msg, keyvals: keyValues})
+	l.cond.Broadcast()
+	l.mu.Unlock()
+}
+
+// Flush 等待队列中已有的日志全部写入，然后刷新被包装的 Logger。
+func (l *AsyncLogger) Flush() error {
+	l.drain()
+	if f, ok := l.logger.(flusher); ok {
+		return f.Flush()
+	}
+	return l.logger.Close()
+}
+
+// Close 写完队列中的日志，停止后台协程并关闭被包装的 Logger。
+func (l *AsyncLogger) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	l.cond.Broadcast()
+	l.mu.Unlock()
+	<-l.done
+	return l.logger.Close()
+}
+
+// Dropped 返回因队列已满而丢弃的日志条数
+func (l *AsyncLogger) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// drain 等待队列为空且后台协程写完取出的日志
+func (l *AsyncLogger) drain() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for !l.queue.IsEmpty() || l.writing {
+		l.cond.Wait()
+	}
+}
+
+// run 是后台写入协程，每次取出队列中的全部日志批量写入
+func (l *AsyncLogger) run() {
+	defer close(l.done)
+	for {
+		l.mu.Lock()
+		for l.queue.IsEmpty() && !l.closed {
+			l.cond.Wait()
+		}
+		if l.queue.IsEmpty() {
+			l.mu.Unlock()
+			return // 已关闭且队列已写完
+		}
+		batch := l.queue.Clear()
+		l.writing = true
+		l.cond.Broadcast()
+		l.mu.Unlock()
+
+		for _, v := range batch {
+			r := v.(record)
+			l.logger.Log(r.ctx, r.level, r.msg, r.keyvals...)
+		}
+
+		l.mu.Lock()
+		l.writing = false
+		l.cond.Broadcast()
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingLogger 记录日志消息，gate 关闭前阻塞写入
+type blockingLogger struct {
+	gate   chan struct{}
+	mu     sync.Mutex
+	msgs   []string
+	closed int
+}
+
+func newBlockingLogger() *blockingLogger {
+	return &blockingLogger{gate: make(chan struct{})}
+}
+
+func (l *blockingLogger) Log(_ context.Context, _ Level, msg string, _ ...interface{}) {
+	<-l.gate
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, msg)
+}
+
+func (l *blockingLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed++
+	return nil
+}
+
+func (l *blockingLogger) messages() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.msgs...)
+}
+
+// fill 写入一条被后台协程取出并阻塞的日志，然后写满队列
+func fill(l *AsyncLogger, n int) {
+	l.Log(context.Background(), InfoLevel, "first")
+	for {
+		l.mu.Lock()
+		writing := l.writing
+		l.mu.Unlock()
+		if writing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < n; i++ {
+		l.Log(context.Background(), InfoLevel, fmt.Sprint(i))
+	}
+}
+
+func TestAsyncLogger_DropPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DropPolicy
+		want   []string
+	}{
+		{"drop newest", DropNewest, []string{"first", "0", "1", "2", "last"}},
+		{"drop oldest", DropOldest, []string{"first", "2", "3", "4", "last"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := newBlockingLogger()
+			l := NewAsyncLogger(inner, WithQueueSize(3), WithDropPolicy(tt.policy))
+			fill(l, 5)
+			close(inner.gate)
+			if err := l.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			l.Log(context.Background(), InfoLevel, "last")
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := inner.messages(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("messages = %v, want %v", got, tt.want)
+			}
+			if l.Dropped() != 2 {
+				t.Errorf("Dropped() = %d, want 2", l.Dropped())
+			}
+		})
+	}
+}
+
+func TestAsyncLogger_Block(t *testing.T) {
+	inner := newBlockingLogger()
+	l := NewAsyncLogger(inner, WithQueueSize(2))
+	fill(l, 2)
+
+	logged := make(chan struct{})
+	go func() {
+		l.Log(context.Background(), InfoLevel, "blocked")
+		close(logged)
+	}()
+	select {
+	case <-logged:
+		t.Fatal("Log did not block on a full queue")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(inner.gate)
+	<-logged
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"first", "0", "1", "blocked"}
+	if got := inner.messages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %v, want %v", got, want)
+	}
+	if l.Dropped() != 0 || inner.closed != 1 {
+		t.Errorf("Dropped() = %d, closed = %d, want 0 and 1", l.Dropped(), inner.closed)
+	}
+
+	l.Log(context.Background(), InfoLevel, "after close")
+	if got := inner.messages(); got[len(got)-1] != "after close" {
+		t.Errorf("messages = %v, want records after Close written synchronously", got)
+	}
+}
+
+func TestAsyncLogger_Adapter(t *testing.T) {
+	inner := newBlockingLogger()
+	close(inner.gate)
+	la := NewAdapter(NewAsyncLogger(inner)).With("k", "v")
+	for i := 0; i < 100; i++ {
+		la.Infof("n=%d", i)
+	}
+	if err := la.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := inner.messages(); len(got) != 100 || got[99] != "n=99" {
+		t.Errorf("messages = %d, want all 100 written by Flush", len(got))
+	}
+	la.Info("still running")
+	if err := la.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := inner.messages(); len(got) != 101 {
+		t.Errorf("messages = %d, want the record after Flush written", len(got))
+	}
+}
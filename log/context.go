@@ -0,0 +1,43 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import "context"
+
+// fieldsKey 是 Context 中日志键值对的键
+type fieldsKey struct{}
+
+// WithFields 返回附加了键值对的 Context，使用该 Context 记录的日志会自动输出这些键值对，
+// 如请求 ID。多次调用时键值对按调用顺序追加。
+func WithFields(ctx context.Context, keyvals ...interface{}) context.Context {
+	if len(keyvals) == 0 {
+		return ctx
+	}
+	prev := FieldsFromContext(ctx)
+	fields := make([]interface{}, 0, len(prev)+len(keyvals))
+	fields = append(append(fields, prev...), keyvals...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// FieldsFromContext 返回 ctx 中通过 WithFields 附加的键值对
+func FieldsFromContext(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	return fields
+}
+
+// withContextFields 将 ctx 中的键值对放在 keyvals 之前
+func withContextFields(ctx context.Context, keyvals []interface{}) []interface{} {
+	fields := FieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return keyvals
+	}
+	result := make([]interface{}, 0, len(fields)+len(keyvals))
+	return append(append(result, fields...), keyvals...)
+}
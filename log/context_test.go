@@ -0,0 +1,90 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestWithFields(t *testing.T) {
+	ctx := WithFields(context.Background(), "request_id", "r1")
+	ctx = WithFields(ctx, "user", "u1")
+	want := []interface{}{"request_id", "r1", "user", "u1"}
+	if got := FieldsFromContext(ctx); !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldsFromContext() = %v, want %v", got, want)
+	}
+	if got := FieldsFromContext(context.Background()); got != nil {
+		t.Errorf("FieldsFromContext(empty) = %v, want nil", got)
+	}
+}
+
+func TestAdapter_CtxMethods_Slog(t *testing.T) {
+	var buf bytes.Buffer
+	la := NewAdapter(NewSlogLogger(&buf, DebugLevel), WithLevel(DebugLevel))
+	ctx := WithFields(context.Background(), "request_id", "r1")
+
+	la.InfoCtx(ctx, "hello")
+	la.ErrorwCtx(ctx, "k", "v")
+	la.DebugfCtx(ctx, "n=%d", 1)
+	la.WarnCtx(nil, "no ctx") // a nil ctx falls back to the default context
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("lines = %q, want 4", lines)
+	}
+	for i, want := range []string{"msg=hello request_id=r1", "request_id=r1 k=v", `msg="n=1" request_id=r1`} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want)
+		}
+	}
+	if strings.Contains(lines[3], "request_id") {
+		t.Errorf("line 3 = %q, want no context fields", lines[3])
+	}
+}
+
+func TestAdapter_CtxMethods_Zap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zap.log")
+	cfg := ZapConfig{Config: zap.NewProductionConfig()}
+	cfg.OutputPaths = []string{path}
+	logger := newZapLogger(cfg)
+	if logger == nil {
+		t.Fatal("newZapLogger() = nil")
+	}
+	defer logger.Close()
+
+	la := NewAdapter(logger, WithContext(WithFields(context.Background(), "app", "a1")))
+	la.Infow("k", "v")
+	la.InfowCtx(WithFields(context.Background(), "request_id", "r1"), "k", "v")
+	_ = logger.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines = %q, want 2", lines)
+	}
+	var first, second map[string]interface{}
+	_ = json.Unmarshal([]byte(lines[0]), &first)
+	_ = json.Unmarshal([]byte(lines[1]), &second)
+	if first["app"] != "a1" || first["k"] != "v" {
+		t.Errorf("first = %v, want the fields of the default context", first)
+	}
+	if second["request_id"] != "r1" || second["k"] != "v" || second["app"] != nil {
+		t.Errorf("second = %v, want the fields of the given context only", second)
+	}
+}
@@ -15,6 +15,8 @@ import (
 var (
 	// 全局默认日志适配器实例
 	defaultAdapter *Adapter
+	// 默认日志记录器，默认适配器和组件适配器都使用它，替换后立即对所有适配器生效
+	root = new(source)
 	// 全局日志配置，包含默认级别和各组件级别
 	cfg = Config{DefaultLevel: InfoLevel, Named: make(map[string]Level)}
 	// 组件名称到日志适配器的映射表
@@ -25,8 +27,10 @@ var (
 
 // Config 定义日志系统的配置结构
 type Config struct {
-	DefaultLevel Level            // 系统默认日志级别
-	Named        map[string]Level // 各组件特定的日志级别配置
+	DefaultLevel Level                `json:"defaultLevel"`         // 系统默认日志级别
+	Named        map[string]Level     `json:"named,omitempty"`      // 各组件特定的日志级别配置
+	Sampling     Sampling             `json:"sampling"`             // 所有适配器的采样策略，每个适配器独立计数
+	RateLimits   map[string]RateLimit `json:"rateLimits,omitempty"` // 各组件的限流策略，每个组件使用独立的令牌桶
 }
 
 // findConfigLevel 实现组件日志级别的层级查找逻辑
@@ -45,8 +49,25 @@ func findConfigLevel(cfg *Config, s string) Level {
 	}
 }
 
+// findRateLimit 与 findConfigLevel 相同，按层级查找组件的限流策略，未配置时返回零值
+func findRateLimit(cfg *Config, s string) RateLimit {
+	name := s
+	for {
+		if r, exists := cfg.RateLimits[name]; exists {
+			return r
+		}
+		if index := strings.LastIndex(name, "."); index > 0 {
+			name = name[:index]
+		} else {
+			return RateLimit{}
+		}
+	}
+}
+
 // Named 获取或创建指定组件的日志适配器
-// 组件名称不区分大小写，会自动转换为小写
+// 组件名称不区分大小写，会自动转换为小写；以"."分隔的名称构成组件层级，
+// 组件没有单独配置级别时继承最近的上级组件的级别，配置变化时所有已创建的适配器随之更新。
+// 适配器记录的每条日志都带有 "logger" 字段，值为组件名称
 func Named(s string) *Adapter {
 	mu.Lock()
 	defer mu.Unlock()
@@ -57,34 +78,83 @@ func Named(s string) *Adapter {
 	}
 
 	level := findConfigLevel(&cfg, s)
-	a := NewAdapter(defaultAdapter.logger, WithLevel(level))
+	a := NewAdapter(nil, WithLevel(level),
+		WithSampling(cfg.Sampling), WithRateLimit(findRateLimit(&cfg, s)))
+	a.src, a.kv, a.name = root, []interface{}{"logger", s}, s
 	adapters[s] = a
 	return a
 }
 
-// SetConfig 更新全局日志配置并刷新所有适配器
+// SetConfig 更新全局日志配置并刷新所有适配器，同时取消所有未到期的临时级别
 func SetConfig(config Config) {
 	mu.Lock()
 	defer mu.Unlock()
 
 	cfg = config
+	stopOverrides()
 	for k, a := range adapters {
 		a.SetLevel(findConfigLevel(&cfg, k))
+		a.SetSampling(cfg.Sampling)
+		a.SetRateLimit(findRateLimit(&cfg, k))
+	}
+	if defaultAdapter != nil {
+		defaultAdapter.SetSampling(cfg.Sampling)
+	}
+}
+
+// Dropped 返回各组件适配器因采样和限流丢弃的日志条数
+func Dropped() map[string]DropStats {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stats := make(map[string]DropStats, len(adapters))
+	for k, a := range adapters {
+		stats[k] = a.Dropped()
 	}
+	return stats
 }
 
 // SetDefaultAdapter 替换默认日志适配器
+// 组件适配器随之使用 adapter 的日志记录器，原有的默认日志记录器被刷新
 func SetDefaultAdapter(adapter *Adapter) {
 	mu.Lock()
-	defer mu.Unlock()
 	defaultAdapter = adapter
+	var old Logger
+	if adapter.src != root {
+		old = root.swap(adapter.backend())
+	}
+	mu.Unlock()
+	closeReplaced(old, adapter.backend())
 }
 
-// SetDefaultLogger 通过Logger接口创建并设置默认适配器
+// SetDefaultLogger 原子地替换默认日志记录器，默认适配器和所有组件适配器立即使用新的日志记录器，
+// 原有的日志记录器被刷新（调用其 Close 方法），以免丢失缓冲的日志
 func SetDefaultLogger(logger Logger) {
 	mu.Lock()
-	defer mu.Unlock()
-	defaultAdapter = NewAdapter(logger, WithLevel(cfg.DefaultLevel))
+	old := root.swap(logger)
+	if defaultAdapter == nil || defaultAdapter.src != root {
+		defaultAdapter = NewAdapter(nil, WithLevel(cfg.DefaultLevel), WithSampling(cfg.Sampling))
+		defaultAdapter.src = root
+	}
+	mu.Unlock()
+	closeReplaced(old, logger)
+}
+
+// outputCloser 由自己打开了输出的 Logger 实现，如 Setup 创建的日志记录器。
+// Close 和 Flush 只刷新日志，输出在日志记录器被替换时才关闭
+type outputCloser interface {
+	closeOutputs()
+}
+
+// closeReplaced 刷新被替换的日志记录器并关闭它打开的输出，
+// 忽略错误（如 zap 同步标准输出时返回的错误）
+func closeReplaced(old, cur Logger) {
+	if old != nil && old != cur {
+		_ = old.Close()
+		if c, ok := old.(outputCloser); ok {
+			c.closeOutputs()
+		}
+	}
 }
 
 // 以下是各级别日志方法的快捷方式，均委托给defaultAdapter处理
@@ -196,17 +266,32 @@ func Flush() error {
 	return defaultAdapter.Flush()
 }
 
-// SetLevel 动态设置指定组件的日志级别
+// SetLevel 动态设置指定组件的日志级别，name 为空时设置默认级别
+// 级别写入全局配置，作用于该组件及其所有没有单独配置级别的下级组件，包括之后创建的组件
 // name: 组件名称
 // lvl: 日志级别字符串(debug/info/warn/error/panic/fatal)
-func SetLevel(name, lvl string) {
+func SetLevel(name, lvl string) error {
+	var l Level
+	if err := l.UnmarshalText([]byte(lvl)); err != nil {
+		return err
+	}
+	SetNamedLevel(name, l, 0)
+	return nil
+}
+
+// ResetLevel 删除指定组件单独配置的日志级别，使其重新继承上级组件的级别
+// 未到期的临时级别同时被取消；name 为空时不做任何操作
+func ResetLevel(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+
 	name = strings.ToLower(name)
-	a, ok := adapters[name]
-	if !ok {
+	if name == "" {
 		return
 	}
-	var l Level
-	if err := l.UnmarshalText([]byte(lvl)); err == nil {
-		a.SetLevel(l)
+	if o, ok := overrides[name]; ok {
+		o.timer.Stop()
+		delete(overrides, name)
 	}
+	setConfigLevel(name, 0, false)
 }
@@ -7,6 +7,8 @@
 package log
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -69,3 +71,47 @@ func TestNamed(t *testing.T) {
 		})
 	}
 }
+
+func TestSetLevel_Hierarchy(t *testing.T) {
+	saved := GetConfig()
+	defer SetConfig(saved)
+	SetConfig(Config{DefaultLevel: InfoLevel, Named: map[string]Level{"h.b.c": ErrorLevel}})
+	abc, abd := Named("h.b.c"), Named("h.b.d")
+
+	if err := SetLevel("h.b", "debug"); err != nil {
+		t.Fatalf("SetLevel() error = %v", err)
+	}
+	if abd.Level() != DebugLevel || abc.Level() != ErrorLevel {
+		t.Errorf("levels = %v, %v, want debug and the explicit error", abd.Level(), abc.Level())
+	}
+	if got := Named("h.b.x.y").Level(); got != DebugLevel {
+		t.Errorf("new descendant level = %v, want debug", got)
+	}
+	if got := Named("h").Named("b").Named("d"); got != abd || got.Name() != "h.b.d" {
+		t.Errorf("Named chain = %v, want the h.b.d adapter", got.Name())
+	}
+
+	ResetLevel("H.B.C")
+	if abc.Level() != DebugLevel {
+		t.Errorf("level after reset = %v, want inherited debug", abc.Level())
+	}
+	ResetLevel("h.b")
+	if abc.Level() != InfoLevel || abd.Level() != InfoLevel {
+		t.Errorf("levels after reset = %v, %v, want the default info", abc.Level(), abd.Level())
+	}
+	if err := SetLevel("h.b", "loud"); err == nil {
+		t.Error("SetLevel() error = nil, want an invalid level error")
+	}
+}
+
+func TestNamed_LoggerField(t *testing.T) {
+	saved := root.load().Logger
+	defer SetDefaultLogger(saved)
+
+	var buf bytes.Buffer
+	SetDefaultLogger(NewSlogLogger(&buf, DebugLevel))
+	Named("field.test").With("k", "v").Info("hello")
+	if got := buf.String(); !strings.Contains(got, "logger=field.test k=v") {
+		t.Errorf("output = %q, want the logger field", got)
+	}
+}
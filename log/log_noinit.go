@@ -0,0 +1,15 @@
+//go:build log_noinit
+
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+// init 使用 log_noinit 构建标签时不读取环境变量和配置文件，
+// 默认日志记录器丢弃所有日志，直到调用 Setup
+func init() {
+	SetDefaultLogger(Discard)
+}
@@ -8,8 +8,8 @@ package log
 
 import (
 	"context"
+	"io"
 	"log/slog"
-	"os"
 )
 
 // 确保 slogLogger 实现了 Logger 接口
@@ -17,7 +17,8 @@ var _ Logger = (*slogLogger)(nil)
 
 // slogLogger 是基于 slog 的日志记录器实现
 type slogLogger struct {
-	log *slog.Logger // 底层的 slog 日志记录器
+	log   *slog.Logger // 底层的 slog 日志记录器
+	close func()       // 关闭 Setup 打开的输出，为 nil 时不需要关闭；通过 With 派生的记录器不关闭输出
 }
 
 // newSlogLogger 创建并返回一个新的 slogLogger 实例
@@ -68,6 +69,7 @@ func customLevel(groups []string, a slog.Attr) slog.Attr {
 
 // Log 实现 Logger 接口的 Log 方法
 func (l *slogLogger) Log(ctx context.Context, level Level, msg string, keyValues ...interface{}) {
+	keyValues = withContextFields(ctx, keyValues)
 	switch level {
 	case DebugLevel:
 		l.log.Log(ctx, levelDebug, msg, keyValues...)
@@ -84,20 +86,35 @@ func (l *slogLogger) Log(ctx context.Context, level Level, msg string, keyValues
 	}
 }
 
-// Close 实现 Logger 接口的 Close 方法
+// With 返回带有 keyvals 的 slogLogger，键值对通过 slog.Logger.With 只编码一次
+func (l *slogLogger) With(keyvals ...interface{}) Logger {
+	return &slogLogger{log: l.log.With(keyvals...)}
+}
+
+// Close 实现 Logger 接口的 Close 方法。slog 直接写入输出，没有需要刷新的缓冲；
+// Setup 打开的输出只在日志记录器被替换时关闭，见 closeOutputs
 func (l *slogLogger) Close() error {
-	return nil // slog 不需要显式关闭
+	return nil
 }
 
-// initSlogLogger 初始化并返回一个 slog 日志记录器
-func initSlogLogger(lvl Level) Logger {
-	logger := newSlogLogger(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		AddSource:   false,    // 不添加源代码位置
-		ReplaceAttr: customLevel, // 使用自定义级别格式
-		Level:       toSlogLevel(lvl), // 设置日志级别
-	}))
+// Flush 实现 flusher，slog 没有缓冲，不做任何操作
+func (l *slogLogger) Flush() error {
+	return nil
+}
 
-	return logger
+// closeOutputs 实现 outputCloser，关闭 Setup 打开的输出
+func (l *slogLogger) closeOutputs() {
+	if l.close != nil {
+		l.close()
+	}
+}
+
+// NewSlogLogger 创建写入 w 的 slog 文本日志记录器，w 可以是 *RotatingFile
+func NewSlogLogger(w io.Writer, lvl Level) Logger {
+	return newSlogLogger(slog.NewTextHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: customLevel,
+		Level:       toSlogLevel(lvl),
+	}))
 }
 
 // toSlogLevel 将自定义 Level 转换为 slog.Level
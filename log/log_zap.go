@@ -8,44 +8,132 @@ package log
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/fsnotify/fsnotify"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"net/url"
 	"os"
-	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
 )
 
+// ZapConfig 是 zap 日志记录器的配置
 type ZapConfig struct {
 	zap.Config
 
+	// Named 各组件的日志级别
 	Named map[string]Level `json:"named" yaml:"named"`
+	// Rotate 滚动日志文件，设置后日志同时写入该文件
+	Rotate *RotateConfig `json:"rotate,omitempty" yaml:"rotate,omitempty"`
 }
 
 var _ Logger = (*zapLogger)(nil)
 
+// zapConfigFile 默认日志记录器使用的 zap 配置文件路径，未使用配置文件时为空
+var zapConfigFile atomic.Value
+
 // zapLogger zap.Logger 的实现
 type zapLogger struct {
-	log *zap.Logger
+	log   *zap.Logger
+	close func() // 关闭 buildZapLogger 打开的输出，为 nil 时不需要关闭；通过 With 派生的记录器不关闭输出
 }
 
 func newZapLogger(cfg ZapConfig) *zapLogger {
+	logger, err := buildZapLogger(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "zap.Config.Build[%v] fail: %v\n", cfg, err)
+		return nil
+	}
+	return logger
+}
+
+// buildZapLogger 根据配置创建 zap 日志记录器
+func buildZapLogger(cfg ZapConfig) (*zapLogger, error) {
+	if cfg.Rotate != nil {
+		cfg.OutputPaths = append(append([]string(nil), cfg.OutputPaths...), cfg.Rotate.URL())
+	}
+	// 预先打开输出并保留关闭函数，cfg.Build 打开的输出无法关闭，每次重新加载都会泄漏文件
+	out, closeOut, err := zap.Open(cfg.OutputPaths...)
+	if err != nil {
+		return nil, err
+	}
+	errOut, closeErrOut, err := zap.Open(cfg.ErrorOutputPaths...)
+	if err != nil {
+		closeOut()
+		return nil, err
+	}
+	outPath, release := openedPath(out)
+	defer release()
+	errOutPath, releaseErr := openedPath(errOut)
+	defer releaseErr()
+	cfg.OutputPaths, cfg.ErrorOutputPaths = []string{outPath}, []string{errOutPath}
+
 	logger, err := cfg.Build(
 		zap.AddCallerSkip(5),
 		zap.AddStacktrace(zapcore.ErrorLevel),
 	)
 	if err != nil {
-		fmt.Printf("zap.Config.Build[%v] fail\n", cfg)
-		return nil
+		closeOut()
+		closeErrOut()
+		return nil, err
 	}
 
 	return &zapLogger{
 		log: logger,
+		close: func() {
+			closeOut()
+			closeErrOut()
+		},
+	}, nil
+}
+
+// openedScheme 是 buildZapLogger 预先打开的输出的 zap 输出路径协议，仅在 cfg.Build 期间有效
+const openedScheme = "log-opened"
+
+var (
+	// openedSinks 预先打开的输出，按 openedScheme 路径中的序号索引
+	openedSinks   = make(map[string]zapcore.WriteSyncer)
+	openedSinksMu sync.Mutex
+	openedSeq     uint64
+)
+
+func init() {
+	_ = zap.RegisterSink(openedScheme, func(u *url.URL) (zap.Sink, error) {
+		openedSinksMu.Lock()
+		ws, ok := openedSinks[u.Host]
+		openedSinksMu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("log: %s: no such output", u)
+		}
+		return nopCloseSink{ws}, nil
+	})
+}
+
+// openedPath 返回引用已打开的输出 ws 的 zap 输出路径，release 之后路径失效
+func openedPath(ws zapcore.WriteSyncer) (path string, release func()) {
+	openedSinksMu.Lock()
+	defer openedSinksMu.Unlock()
+	openedSeq++
+	id := strconv.FormatUint(openedSeq, 10)
+	openedSinks[id] = ws
+	return openedScheme + "://" + id, func() {
+		openedSinksMu.Lock()
+		defer openedSinksMu.Unlock()
+		delete(openedSinks, id)
 	}
 }
 
-func (l *zapLogger) Log(_ context.Context, level Level, msg string, keyValues ...interface{}) {
+// nopCloseSink 是不关闭 WriteSyncer 的 zap.Sink，输出由打开它的 zapLogger 关闭
+type nopCloseSink struct {
+	zapcore.WriteSyncer
+}
+
+func (nopCloseSink) Close() error { return nil }
+
+func (l *zapLogger) Log(ctx context.Context, level Level, msg string, keyValues ...interface{}) {
+	keyValues = withContextFields(ctx, keyValues)
 	var fields []zap.Field
 	var f zap.Field
 	for len(keyValues) > 0 {
@@ -69,10 +157,29 @@ func (l *zapLogger) Log(_ context.Context, level Level, msg string, keyValues ..
 	}
 }
 
+// With 返回带有 keyvals 的 zapLogger，键值对通过 zap.Logger.With 只编码一次
+func (l *zapLogger) With(keyvals ...interface{}) Logger {
+	var fields []zap.Field
+	var f zap.Field
+	for len(keyvals) > 0 {
+		f, keyvals = keyValuesToField(keyvals)
+		fields = append(fields, f)
+	}
+	return &zapLogger{log: l.log.With(fields...)}
+}
+
+// Close 刷新缓冲的日志，输出在日志记录器被替换时才关闭，见 closeOutputs
 func (l *zapLogger) Close() error {
 	return l.log.Sync()
 }
 
+// closeOutputs 实现 outputCloser，关闭 buildZapLogger 打开的输出
+func (l *zapLogger) closeOutputs() {
+	if l.close != nil {
+		l.close()
+	}
+}
+
 const badKey = "!BADKEY"
 
 func keyValuesToField(args []interface{}) (zap.Field, []interface{}) {
@@ -91,93 +198,19 @@ func keyValuesToField(args []interface{}) (zap.Field, []interface{}) {
 	}
 }
 
-func initZapLogger(zapConfig string, lvl Level) Logger {
-	var logger *zapLogger
-
-	defer func() {
-		if logger != nil {
-			zap.RedirectStdLog(logger.log)
-		}
-	}()
-
-	file, err := filepath.Abs(zapConfig)
-	if err == nil {
-		logger, err = buildFrom(file)
-		if err != nil {
-			fmt.Println("error:", err)
-		} else {
-			go func(path string) {
-				err := watch(path)
-				if err != nil {
-					fmt.Println("error:", err)
-				}
-			}(file)
-		}
-		return logger
-
-	} //允许配置不存在或者配置错误
-
-	config := zap.NewProductionConfig()
-	config.Level.SetLevel(lvl)
-	config.Encoding = "console"
-	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-
-	if val, ok := os.LookupEnv("DEBUG"); ok && val != "" {
-		config.Level.SetLevel(zapcore.DebugLevel)
-		config.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+// Reload 重新打开所有滚动日志文件，并重新读取 zap 配置文件替换默认日志记录器，结果通过 OnReload 通知
+// 未使用 zap 配置文件时只重新打开日志文件；重新打开失败时仍然重新读取配置文件，并返回所有错误
+func Reload() error {
+	err := Reopen()
+	file, _ := zapConfigFile.Load().(string)
+	if file == "" {
+		return err
 	}
-
-	logger = newZapLogger(ZapConfig{Config: config})
-	return logger
-}
-
-func watch(path string) error {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		fmt.Println(err)
-	}
-	defer watcher.Close()
-
-	err = watcher.Add(path)
-	for {
-		select {
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return nil
-			}
-			fmt.Println("event:", event)
-			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Rename == fsnotify.Rename {
-				fmt.Print("modified file:", event.Name)
-				logger, err := buildFrom(path)
-				if err != nil {
-					fmt.Println("error:", err)
-				} else {
-					SetDefaultLogger(logger)
-				}
-
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return err
-			}
-			fmt.Println("error:", err)
-		}
-	}
-
+	return errors.Join(err, reloadZap(file))
 }
 
 func buildFrom(file string) (*zapLogger, error) {
-	config := ZapConfig{
-		Config: zap.NewProductionConfig(),
-		Named:  make(map[string]Level),
-	}
-
-	fmt.Printf("Loading config: %v\n", file)
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	err = json.NewDecoder(f).Decode(&config)
+	config, err := LoadZapConfig(file)
 	if err != nil {
 		return nil, err
 	}
@@ -193,12 +226,15 @@ func buildFrom(file string) (*zapLogger, error) {
 	}
 	config.Level.SetLevel(minLevel)
 
-	logger := newZapLogger(config)
-	SetConfig(Config{
-		DefaultLevel: defaultLevel,
-		Named:        config.Named,
-	})
-
-	fmt.Println("default level:", defaultLevel)
+	logger, err := buildZapLogger(config)
+	if err != nil {
+		return nil, fmt.Errorf("log: %s: %w", file, err)
+	}
+	mu.Lock()
+	c := cfg // 保留通过 SetConfig 设置的采样和限流策略
+	mu.Unlock()
+	c.DefaultLevel = defaultLevel
+	c.Named = config.Named
+	SetConfig(c)
 	return logger, nil
 }
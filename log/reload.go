@@ -0,0 +1,119 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-inspire/pkg/internal/fswatch"
+)
+
+// DefaultReloadDebounce 是默认日志记录器监听 zap 配置文件时合并变化事件的时间
+const DefaultReloadDebounce = 100 * time.Millisecond
+
+// ReloadEvent 描述一次 zap 配置文件重新加载的结果
+type ReloadEvent struct {
+	File string    // 配置文件路径
+	Time time.Time // 重新加载的时间
+	// Err 重新加载失败或监听出错的原因，为 nil 表示已替换默认日志记录器；
+	// 失败时继续使用原有的日志记录器
+	Err error
+}
+
+var (
+	// reloadMu 串行化 zap 配置文件的重新加载
+	reloadMu sync.Mutex
+
+	subsMu  sync.Mutex
+	subs    = make(map[int]func(ReloadEvent))
+	nextSub int
+
+	watcherMu sync.Mutex
+	watcher   *fswatch.Watcher
+)
+
+// OnReload 订阅 zap 配置文件的重新加载事件，返回取消订阅的函数
+// fn 在重新加载的协程中同步调用，不应调用 Reload、WatchZapConfig 或 StopWatch
+func OnReload(fn func(ReloadEvent)) (cancel func()) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	id := nextSub
+	nextSub++
+	subs[id] = fn
+	return func() {
+		subsMu.Lock()
+		defer subsMu.Unlock()
+		delete(subs, id)
+	}
+}
+
+// notifyReload 通知所有订阅者
+func notifyReload(e ReloadEvent) {
+	subsMu.Lock()
+	fns := make([]func(ReloadEvent), 0, len(subs))
+	for _, fn := range subs {
+		fns = append(fns, fn)
+	}
+	subsMu.Unlock()
+	for _, fn := range fns {
+		fn(e)
+	}
+}
+
+// reloadZap 重新读取 zap 配置文件并原子地替换默认日志记录器，原有的日志记录器被刷新
+func reloadZap(file string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	logger, err := buildFrom(file)
+	if err == nil {
+		SetDefaultLogger(logger)
+	}
+	notifyReload(ReloadEvent{File: file, Time: time.Now(), Err: err})
+	return err
+}
+
+// WatchZapConfig 监听 zap 配置文件，文件变化后在 debounce 时间内合并多次事件，
+// 再重新加载并替换默认日志记录器，结果通过 OnReload 通知
+// 监听的是文件所在的目录，编辑器通过重命名替换文件时也能收到事件；已有的监听会被停止
+func WatchZapConfig(file string, debounce time.Duration) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	w, err := fswatch.New([]string{abs}, debounce, func() { _ = reloadZap(abs) }, func(err error) {
+		notifyReload(ReloadEvent{File: abs, Time: time.Now(), Err: fmt.Errorf("log: watch %s: %w", abs, err)})
+	})
+	if err != nil {
+		return err
+	}
+	zapConfigFile.Store(abs)
+
+	watcherMu.Lock()
+	old := watcher
+	watcher = w
+	watcherMu.Unlock()
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+// StopWatch 停止监听 zap 配置文件，没有监听时不做任何操作
+func StopWatch() error {
+	watcherMu.Lock()
+	w := watcher
+	watcher = nil
+	watcherMu.Unlock()
+	if w != nil {
+		return w.Close()
+	}
+	return nil
+}
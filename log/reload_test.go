@@ -0,0 +1,180 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetDefaultLogger_Swap(t *testing.T) {
+	saved := root.load().Logger
+	defer SetDefaultLogger(saved)
+
+	first := &blockingLogger{gate: make(chan struct{})}
+	close(first.gate)
+	SetDefaultLogger(first)
+	named := Named("swap.test")
+	child := named.With("k", "v")
+
+	var buf bytes.Buffer
+	SetDefaultLogger(NewSlogLogger(&buf, DebugLevel))
+	if first.closed != 1 {
+		t.Errorf("replaced logger closed %d times, want flushed once", first.closed)
+	}
+	named.Info("to the new logger")
+	child.Info("child too")
+	Error("default too")
+	out := buf.String()
+	for _, want := range []string{`msg="to the new logger" logger=swap.test`, `msg="child too" logger=swap.test k=v`, `msg="default too"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+	}
+	if len(first.messages()) != 0 {
+		t.Errorf("replaced logger got %v", first.messages())
+	}
+}
+
+func TestWatchZapConfig(t *testing.T) {
+	saved := root.load().Logger
+	savedCfg := GetConfig()
+	defer func() {
+		_ = StopWatch()
+		zapConfigFile.Store("")
+		SetDefaultLogger(saved)
+		SetConfig(savedCfg)
+	}()
+
+	dir := t.TempDir()
+	out := filepath.ToSlash(filepath.Join(dir, "app.log"))
+	file := filepath.Join(dir, "zap.config.yaml")
+	write := func(level string) {
+		t.Helper()
+		tmp := file + ".tmp"
+		content := "level: " + level + "\nencoding: json\noutputPaths: [\"" + out + "\"]\n"
+		if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, file); err != nil { // 编辑器通过重命名替换文件
+			t.Fatal(err)
+		}
+	}
+	write("info")
+	if err := WatchZapConfig(file, 20*time.Millisecond); err != nil {
+		t.Fatalf("WatchZapConfig() error = %v", err)
+	}
+	events := make(chan ReloadEvent, 10)
+	cancel := OnReload(func(e ReloadEvent) { events <- e })
+	defer cancel()
+	next := func() ReloadEvent {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("no reload event")
+			return ReloadEvent{}
+		}
+	}
+
+	component := Named("watch.test")
+	write("warn")
+	if e := next(); e.Err != nil || e.File != file {
+		t.Fatalf("event = %+v, want a successful reload of %s", e, file)
+	}
+	if GetConfig().DefaultLevel != WarnLevel || component.Level() != WarnLevel {
+		t.Errorf("level = %v, want warn", component.Level())
+	}
+	component.Warn("after reload")
+	_ = Flush()
+	if b, _ := os.ReadFile(out); !strings.Contains(string(b), `"msg":"after reload","logger":"watch.test"`) {
+		t.Errorf("log file = %q, want the record from the reloaded logger", b)
+	}
+
+	write("loud")
+	if e := next(); e.Err == nil || !strings.Contains(e.Err.Error(), "level: unrecognized level") {
+		t.Errorf("event = %+v, want the validation error", e)
+	}
+	if component.Level() != WarnLevel {
+		t.Errorf("level = %v, want the previous config kept", component.Level())
+	}
+
+	if err := StopWatch(); err != nil {
+		t.Fatal(err)
+	}
+	write("error")
+	select {
+	case e := <-events:
+		t.Errorf("event %+v after StopWatch", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReload_ReopenFailure(t *testing.T) {
+	restoreDefault(t)
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	openTestFile(t, RotateConfig{Filename: filepath.Join(sub, "app.log")}, nil)
+	if err := os.RemoveAll(sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sub, nil, 0o644); err != nil { // the directory cannot be created again
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "app.log")
+	file := writeConfig(t, dir, "zap.config.yaml", "level: info\nencoding: json\noutputPaths: [\""+filepath.ToSlash(out)+"\"]\n")
+	zapConfigFile.Store(file)
+	if err := Reload(); err == nil {
+		t.Error("Reload() error = nil, want the reopen error")
+	}
+	Error("after reload")
+	_ = Flush()
+	if b, _ := os.ReadFile(out); !strings.Contains(string(b), `"msg":"after reload"`) {
+		t.Errorf("log file = %q, want the config reloaded despite the reopen error", b)
+	}
+}
+
+func TestReload_ClosesReplacedOutputs(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("no /proc/self/fd")
+	}
+	restoreDefault(t)
+	dir := t.TempDir()
+	out := filepath.ToSlash(filepath.Join(dir, "app.log"))
+	file := writeConfig(t, dir, "zap.config.yaml", "level: info\nencoding: json\noutputPaths: [\""+out+"\"]\nerrorOutputPaths: [\""+out+"\"]\n")
+	zapConfigFile.Store(file)
+	openFiles := func() int {
+		fds, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(fds)
+	}
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	before := openFiles()
+	for i := 0; i < 20; i++ {
+		if err := Reload(); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+	}
+	if n := openFiles(); n > before {
+		t.Errorf("open files = %d after 20 reloads, want %d", n, before)
+	}
+	Error("after reloads")
+	_ = Flush()
+	if b, _ := os.ReadFile(out); !strings.Contains(string(b), `"msg":"after reloads"`) {
+		t.Errorf("log file = %q, want the record from the current logger", b)
+	}
+}
@@ -0,0 +1,522 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RotateScheme 是滚动日志文件的 zap 输出路径协议，
+// 如 rotate:///var/log/app.log?maxSize=100&maxBackups=7&compress=true
+const RotateScheme = "rotate"
+
+// backupTimeFormat 是备份文件名中的时间格式
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateConfig 定义滚动日志文件的配置
+type RotateConfig struct {
+	// Filename 日志文件路径
+	Filename string `json:"filename" yaml:"filename"`
+	// MaxSize 单个文件的最大大小(MB)，超过后滚动，0 表示不按大小滚动
+	MaxSize int `json:"maxSize" yaml:"maxSize"`
+	// Interval 按时间滚动的周期，如 24h 表示每天滚动一次，0 表示不按时间滚动
+	// 滚动时刻对齐到 Interval 的整数倍，按 LocalTime 选择时区
+	Interval Duration `json:"interval" yaml:"interval"`
+	// MaxAge 备份文件的最长保留时间，0 表示不按时间清理
+	MaxAge Duration `json:"maxAge" yaml:"maxAge"`
+	// MaxBackups 最多保留的备份文件数，0 表示不按数量清理
+	MaxBackups int `json:"maxBackups" yaml:"maxBackups"`
+	// Compress 是否使用 gzip 压缩备份文件
+	Compress bool `json:"compress" yaml:"compress"`
+	// LocalTime 备份文件名和按时间滚动是否使用本地时间，默认使用 UTC
+	LocalTime bool `json:"localTime" yaml:"localTime"`
+}
+
+// Duration 是可以从 "24h" 这样的字符串解析的 time.Duration
+type Duration time.Duration
+
+// UnmarshalText 实现 encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText 实现 encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// URL 返回配置对应的 zap 输出路径
+func (c RotateConfig) URL() string {
+	q := url.Values{}
+	if c.MaxSize > 0 {
+		q.Set("maxSize", strconv.Itoa(c.MaxSize))
+	}
+	if c.Interval > 0 {
+		q.Set("interval", time.Duration(c.Interval).String())
+	}
+	if c.MaxAge > 0 {
+		q.Set("maxAge", time.Duration(c.MaxAge).String())
+	}
+	if c.MaxBackups > 0 {
+		q.Set("maxBackups", strconv.Itoa(c.MaxBackups))
+	}
+	if c.Compress {
+		q.Set("compress", "true")
+	}
+	if c.LocalTime {
+		q.Set("localTime", "true")
+	}
+	u := url.URL{Scheme: RotateScheme, Path: rotateURLPath(c.Filename), RawQuery: q.Encode()}
+	return u.String()
+}
+
+// rotateURLPath 返回文件 name 在 rotate:// 路径中的路径部分。
+// 相对路径转换为绝对路径，否则第一段会被解析为 URL 的主机；
+// Windows 盘符路径以 / 开头，如 /C:/logs/app.log
+func rotateURLPath(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		name = abs
+	}
+	p := filepath.ToSlash(name)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
+
+// parseRotateURL 解析 zap 输出路径中的滚动日志文件配置
+func parseRotateURL(u *url.URL) (RotateConfig, error) {
+	if u.Host != "" {
+		return RotateConfig{}, fmt.Errorf("log: %s: unexpected host %q, use %s:///path", u, u.Host, RotateScheme)
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" && len(p) >= 3 && p[0] == '/' && p[2] == ':' { // /C:/logs/app.log
+		p = p[1:]
+	}
+	c := RotateConfig{Filename: filepath.FromSlash(p)}
+	if c.Filename == "" {
+		return c, fmt.Errorf("log: %s: missing filename", u)
+	}
+	q := u.Query()
+	var err error
+	parse := func(key string, fn func(string) error) {
+		if s := q.Get(key); s != "" && err == nil {
+			if e := fn(s); e != nil {
+				err = fmt.Errorf("log: %s: %s: %w", u, key, e)
+			}
+		}
+	}
+	parse("maxSize", func(s string) (e error) { c.MaxSize, e = strconv.Atoi(s); return })
+	parse("interval", func(s string) error { return c.Interval.UnmarshalText([]byte(s)) })
+	parse("maxAge", func(s string) error { return c.MaxAge.UnmarshalText([]byte(s)) })
+	parse("maxBackups", func(s string) (e error) { c.MaxBackups, e = strconv.Atoi(s); return })
+	parse("compress", func(s string) (e error) { c.Compress, e = strconv.ParseBool(s); return })
+	parse("localTime", func(s string) (e error) { c.LocalTime, e = strconv.ParseBool(s); return })
+	return c, err
+}
+
+var (
+	// rotatingFiles 所有打开的滚动日志文件，按绝对路径索引，Reopen 会重新打开它们
+	rotatingFiles   = make(map[string]*RotatingFile)
+	rotatingFilesMu sync.Mutex
+)
+
+func init() {
+	_ = zap.RegisterSink(RotateScheme, func(u *url.URL) (zap.Sink, error) {
+		c, err := parseRotateURL(u)
+		if err != nil {
+			return nil, err
+		}
+		f, err := OpenRotatingFile(c)
+		if err != nil {
+			return nil, err
+		}
+		return sharedSink{f}, nil
+	})
+}
+
+// sharedSink 是共享的滚动日志文件的 zap.Sink，重新构建 zap 日志记录器时不关闭文件
+type sharedSink struct {
+	*RotatingFile
+}
+
+func (sharedSink) Close() error { return nil }
+
+// Reopen 重新打开所有滚动日志文件，用于外部工具移动日志文件之后，通常在收到 SIGHUP 时调用
+func Reopen() error {
+	rotatingFilesMu.Lock()
+	files := make([]*RotatingFile, 0, len(rotatingFiles))
+	for _, f := range rotatingFiles {
+		files = append(files, f)
+	}
+	rotatingFilesMu.Unlock()
+
+	var errs []error
+	for _, f := range files {
+		errs = append(errs, f.Reopen())
+	}
+	return errors.Join(errs...)
+}
+
+// RotatingFile 是按大小和时间滚动的日志文件，实现了 io.WriteCloser 和 zap.Sink，
+// 可以被多个 goroutine 并发写入
+type RotatingFile struct {
+	path string // 绝对路径
+
+	mu     sync.Mutex
+	cfg    RotateConfig
+	file   *os.File
+	size   int64
+	next   time.Time // 下次按时间滚动的时刻
+	closed bool
+
+	millCh   chan struct{}
+	millDone chan struct{}
+
+	now func() time.Time
+}
+
+// OpenRotatingFile 打开滚动日志文件，文件不存在时创建；
+// 同一路径的文件只会打开一次，再次打开时更新配置并返回同一实例
+func OpenRotatingFile(cfg RotateConfig) (*RotatingFile, error) {
+	path, err := filepath.Abs(cfg.Filename)
+	if err != nil {
+		return nil, err
+	}
+	rotatingFilesMu.Lock()
+	defer rotatingFilesMu.Unlock()
+	if f, ok := rotatingFiles[path]; ok {
+		f.mu.Lock()
+		f.cfg = cfg
+		f.next = f.nextRotation()
+		f.mu.Unlock()
+		return f, nil
+	}
+
+	f := &RotatingFile{
+		path:     path,
+		cfg:      cfg,
+		millCh:   make(chan struct{}, 1),
+		millDone: make(chan struct{}),
+		now:      time.Now,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	go f.millRun()
+	rotatingFiles[path] = f
+	return f, nil
+}
+
+// Write 写入日志，写入前按需滚动文件
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	max := int64(f.cfg.MaxSize) * 1024 * 1024
+	if (max > 0 && f.size > 0 && f.size+int64(len(p)) > max) || (!f.next.IsZero() && !f.now().Before(f.next)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Sync 将文件内容刷新到磁盘
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Rotate 立即滚动文件
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Reopen 关闭并重新打开日志文件，文件被外部移走时会创建新文件
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	if err := f.closeFile(); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// Close 关闭文件并停止清理备份文件
+func (f *RotatingFile) Close() error {
+	rotatingFilesMu.Lock()
+	if rotatingFiles[f.path] == f {
+		delete(rotatingFiles, f.path)
+	}
+	rotatingFilesMu.Unlock()
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	err := f.closeFile()
+	f.mu.Unlock()
+
+	close(f.millCh)
+	<-f.millDone
+	return err
+}
+
+// open 以追加方式打开日志文件，f.mu 必须被持有
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	f.next = f.nextRotation()
+	return nil
+}
+
+// closeFile 关闭当前文件，f.mu 必须被持有
+func (f *RotatingFile) closeFile() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// rotate 将当前文件重命名为备份文件并打开新文件，f.mu 必须被持有
+func (f *RotatingFile) rotate() error {
+	if err := f.closeFile(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(f.path); err == nil {
+		if err := os.Rename(f.path, f.uniqueBackupName(f.localNow())); err != nil {
+			return err
+		}
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	select {
+	case f.millCh <- struct{}{}:
+	default: // 已有待执行的清理
+	}
+	return nil
+}
+
+// localNow 返回按 LocalTime 选择时区的当前时间
+func (f *RotatingFile) localNow() time.Time {
+	if f.cfg.LocalTime {
+		return f.now()
+	}
+	return f.now().UTC()
+}
+
+// nextRotation 返回下次按时间滚动的时刻，不按时间滚动时返回零值
+func (f *RotatingFile) nextRotation() time.Time {
+	interval := time.Duration(f.cfg.Interval)
+	if interval <= 0 {
+		return time.Time{}
+	}
+	now := f.localNow()
+	_, offset := now.Zone()
+	shift := time.Duration(offset) * time.Second
+	// 按本地时区对齐，使 24h 的周期在午夜滚动
+	return now.Add(shift).Truncate(interval).Add(interval).Add(-shift)
+}
+
+// backupName 返回 t 时刻滚动的备份文件名，如 app-2006-01-02T15-04-05.000.log
+func (f *RotatingFile) backupName(t time.Time) string {
+	dir, name := filepath.Split(f.path)
+	ext := filepath.Ext(name)
+	prefix := name[:len(name)-len(ext)]
+	return filepath.Join(dir, prefix+"-"+t.Format(backupTimeFormat)+ext)
+}
+
+// uniqueBackupName 返回 t 时刻滚动的备份文件名，同一毫秒内多次滚动时加上序号，
+// 如 app-2006-01-02T15-04-05.000-1.log，避免覆盖已有的备份文件
+func (f *RotatingFile) uniqueBackupName(t time.Time) string {
+	name := f.backupName(t)
+	ext := filepath.Ext(name)
+	base := name[:len(name)-len(ext)]
+	for seq := 1; exists(name) || exists(name+".gz"); seq++ {
+		name = base + "-" + strconv.Itoa(seq) + ext
+	}
+	return name
+}
+
+// exists 报告文件 path 是否存在
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// backup 是一个备份文件
+type backup struct {
+	path string
+	t    time.Time
+	seq  int // 同一毫秒内滚动的序号
+}
+
+// backups 返回所有备份文件，按时间从新到旧排序
+func (f *RotatingFile) backups(localTime bool) ([]backup, error) {
+	loc := time.UTC
+	if localTime {
+		loc = time.Local
+	}
+	dir, name := filepath.Split(f.path)
+	ext := filepath.Ext(name)
+	prefix := name[:len(name)-len(ext)] + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var result []backup
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() || !strings.HasPrefix(n, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimSuffix(n[len(prefix):], ".gz"), ext)
+		seq := 0
+		if len(ts) > len(backupTimeFormat)+1 && ts[len(backupTimeFormat)] == '-' {
+			if seq, err = strconv.Atoi(ts[len(backupTimeFormat)+1:]); err != nil {
+				continue
+			}
+			ts = ts[:len(backupTimeFormat)]
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, ts, loc)
+		if err != nil {
+			continue
+		}
+		result = append(result, backup{path: filepath.Join(dir, n), t: t, seq: seq})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].t.Equal(result[j].t) {
+			return result[i].seq > result[j].seq
+		}
+		return result[i].t.After(result[j].t)
+	})
+	return result, nil
+}
+
+// millRun 在滚动后压缩和清理备份文件，直到文件关闭
+func (f *RotatingFile) millRun() {
+	defer close(f.millDone)
+	for range f.millCh {
+		_ = f.mill()
+	}
+}
+
+// mill 按 MaxBackups 和 MaxAge 删除过期的备份文件，并按需压缩其余的备份文件
+func (f *RotatingFile) mill() error {
+	f.mu.Lock()
+	cfg := f.cfg
+	now := f.now()
+	f.mu.Unlock()
+
+	backups, err := f.backups(cfg.LocalTime)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, b := range backups {
+		expired := cfg.MaxBackups > 0 && i >= cfg.MaxBackups
+		if cfg.MaxAge > 0 && now.Sub(b.t) > time.Duration(cfg.MaxAge) {
+			expired = true
+		}
+		switch {
+		case expired:
+			errs = append(errs, os.Remove(b.path))
+		case cfg.Compress && !strings.HasSuffix(b.path, ".gz"):
+			errs = append(errs, compress(b.path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compress 将文件压缩为 path.gz 并删除原文件
+func compress(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(path + ".gz")
+		}
+	}()
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeClock is a settable clock for RotatingFile.now.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func openTestFile(t *testing.T, cfg RotateConfig, clock *fakeClock) *RotatingFile {
+	t.Helper()
+	f, err := OpenRotatingFile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	if clock != nil {
+		f.mu.Lock()
+		f.now = clock.now
+		f.next = f.nextRotation()
+		f.mu.Unlock()
+	}
+	return f
+}
+
+func waitBackups(t *testing.T, f *RotatingFile, n int, suffix string) []backup {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		backups, err := f.backups(f.cfg.LocalTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok := len(backups) == n
+		for _, b := range backups {
+			ok = ok && strings.HasSuffix(b.path, suffix)
+		}
+		if ok {
+			return backups
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backups = %v, want %d ending with %q", backups, n, suffix)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRotatingFile_Size(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f := openTestFile(t, RotateConfig{Filename: filepath.Join(dir, "app.log"), MaxSize: 1, MaxBackups: 2}, clock)
+
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+	for i := 0; i < 4*1024; i++ { // 4MB, 3 rotations
+		if i%1024 == 0 {
+			clock.add(time.Second)
+		}
+		if _, err := f.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups := waitBackups(t, f, 2, ".log")
+	if want := filepath.Join(dir, "app-2024-01-01T00-00-04.000.log"); backups[0].path != want {
+		t.Errorf("newest backup = %s, want %s", backups[0].path, want)
+	}
+	info, err := os.Stat(filepath.Join(dir, "app.log"))
+	if err != nil || info.Size() != 1024*1024 {
+		t.Errorf("current file = %v, %v, want 1MB", info, err)
+	}
+}
+
+func TestRotatingFile_SameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f := openTestFile(t, RotateConfig{Filename: filepath.Join(dir, "app.log"), MaxSize: 1}, clock)
+
+	chunk := []byte(strings.Repeat("x", 1024*1024))
+	for i := 0; i < 4; i++ { // 3 rotations without the clock moving
+		if _, err := f.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups := waitBackups(t, f, 3, ".log")
+	want := []string{"app-2024-01-01T00-00-00.000-2.log", "app-2024-01-01T00-00-00.000-1.log", "app-2024-01-01T00-00-00.000.log"}
+	for i, b := range backups {
+		if filepath.Base(b.path) != want[i] {
+			t.Errorf("backups[%d] = %s, want %s", i, filepath.Base(b.path), want[i])
+		}
+	}
+}
+
+func TestRotatingFile_IntervalCompress(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)}
+	f := openTestFile(t, RotateConfig{
+		Filename: filepath.Join(dir, "app.log"),
+		Interval: Duration(24 * time.Hour),
+		MaxAge:   Duration(48 * time.Hour),
+		Compress: true,
+	}, clock)
+
+	_, _ = io.WriteString(f, "day 1\n")
+	clock.add(time.Hour) // midnight
+	_, _ = io.WriteString(f, "day 2\n")
+	backups := waitBackups(t, f, 1, ".log.gz")
+	if want := filepath.Join(dir, "app-2024-01-02T00-00-00.000.log.gz"); backups[0].path != want {
+		t.Errorf("backup = %s, want %s", backups[0].path, want)
+	}
+	r, err := os.Open(backups[0].path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != "day 1\n" {
+		t.Errorf("backup content = %q, want day 1", b)
+	}
+
+	clock.add(72 * time.Hour) // the first backup expires
+	_, _ = io.WriteString(f, "day 5\n")
+	backups = waitBackups(t, f, 1, ".log.gz")
+	if want := filepath.Join(dir, "app-2024-01-05T00-00-00.000.log.gz"); backups[0].path != want {
+		t.Errorf("backup = %s, want %s", backups[0].path, want)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f := openTestFile(t, RotateConfig{Filename: path}, nil)
+
+	_, _ = io.WriteString(f, "before\n")
+	if err := os.Rename(path, path+".1"); err != nil { // logrotate moves the file
+		t.Fatal(err)
+	}
+	if err := Reopen(); err != nil {
+		t.Fatalf("Reopen() error = %v", err)
+	}
+	_, _ = io.WriteString(f, "after\n")
+	if b, _ := os.ReadFile(path); string(b) != "after\n" {
+		t.Errorf("reopened file = %q, want after", b)
+	}
+	if b, _ := os.ReadFile(path + ".1"); string(b) != "before\n" {
+		t.Errorf("moved file = %q, want before", b)
+	}
+}
+
+func TestRotateURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zap.log")
+	cfg := ZapConfig{Config: zap.NewProductionConfig()}
+	cfg.OutputPaths = nil
+	cfg.Rotate = &RotateConfig{Filename: path, MaxSize: 10, MaxBackups: 3, Compress: true}
+	l := newZapLogger(cfg)
+	if l == nil {
+		t.Fatal("newZapLogger() = nil")
+	}
+	l.Log(context.Background(), InfoLevel, "to file", "k", "v")
+	_ = l.Close()
+
+	rotatingFilesMu.Lock()
+	f := rotatingFiles[path]
+	rotatingFilesMu.Unlock()
+	if f == nil {
+		t.Fatal("the rotate sink is not opened")
+	}
+	defer f.Close()
+	if f.cfg != *cfg.Rotate {
+		t.Errorf("sink config = %+v, want %+v", f.cfg, *cfg.Rotate)
+	}
+
+	logger := NewSlogLogger(f, InfoLevel)
+	logger.Log(context.Background(), InfoLevel, "from slog")
+	b, _ := os.ReadFile(path)
+	if !strings.Contains(string(b), `"msg":"to file"`) || !strings.Contains(string(b), "msg=\"from slog\"") {
+		t.Errorf("file = %q, want both records", b)
+	}
+}
+
+func TestRotateConfig_URL(t *testing.T) {
+	for _, name := range []string{"logs/app.log", "C:/logs/app.log", filepath.Join(t.TempDir(), "app.log")} {
+		u, err := url.Parse(RotateConfig{Filename: name, MaxSize: 10}.URL())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if u.Host != "" {
+			t.Errorf("%s: URL %s has host %q", name, u, u.Host)
+		}
+		c, err := parseRotateURL(u)
+		if err != nil {
+			t.Fatalf("%s: parseRotateURL() error = %v", name, err)
+		}
+		want, _ := filepath.Abs(name)
+		if c.Filename != want || c.MaxSize != 10 {
+			t.Errorf("%s: parsed %+v, want filename %s", name, c, want)
+		}
+	}
+	u, _ := url.Parse("rotate://logs/app.log")
+	if _, err := parseRotateURL(u); err == nil {
+		t.Error("parseRotateURL(rotate://logs/app.log) error = nil, want the host rejected")
+	}
+}
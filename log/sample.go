@@ -0,0 +1,256 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"math"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sampling 定义日志采样策略。
+// 在每个 Tick 周期内，相同级别和消息的日志先记录 Initial 条，之后每 Thereafter 条记录一条，
+// 其余的被丢弃。没有消息的结构化日志(如 Errorw)按级别和调用位置计数。零值表示不采样。
+type Sampling struct {
+	Tick       time.Duration `json:"tick,omitempty"`       // 采样周期，默认为 1 秒
+	Initial    int           `json:"initial,omitempty"`    // 每个周期内先记录的条数
+	Thereafter int           `json:"thereafter,omitempty"` // 超过 Initial 后每多少条记录一条，为 0 时丢弃其余所有日志
+}
+
+// RateLimit 定义令牌桶限流策略。
+// 令牌以每秒 Rate 个的速度生成，最多积累 Burst 个，每条日志消耗一个令牌，
+// 没有令牌时日志被丢弃。零值表示不限流。
+type RateLimit struct {
+	Rate  float64 `json:"rate"`            // 每秒生成的令牌数
+	Burst int     `json:"burst,omitempty"` // 令牌桶容量，小于 1 时为 1
+}
+
+// DropStats 记录被丢弃的日志条数
+type DropStats struct {
+	Sampled     uint64 `json:"sampled"`     // 被采样丢弃的条数
+	RateLimited uint64 `json:"rateLimited"` // 被限流丢弃的条数
+}
+
+// WithSampling 返回一个 Option，用于设置 Adapter 的采样策略。
+func WithSampling(s Sampling) Option {
+	return func(la *Adapter) {
+		la.SetSampling(s)
+	}
+}
+
+// WithRateLimit 返回一个 Option，用于设置 Adapter 的限流策略。
+func WithRateLimit(r RateLimit) Option {
+	return func(la *Adapter) {
+		la.SetRateLimit(r)
+	}
+}
+
+// SetSampling 更改适配器的采样策略，零值关闭采样。
+// 采样策略与通过 With 派生的适配器共享。
+func (la *Adapter) SetSampling(s Sampling) *Adapter {
+	la.drop.setSampling(s)
+	return la
+}
+
+// SetRateLimit 更改适配器的限流策略，零值关闭限流。
+// 限流策略与通过 With 派生的适配器共享。
+func (la *Adapter) SetRateLimit(r RateLimit) *Adapter {
+	la.drop.setRateLimit(r)
+	return la
+}
+
+// Dropped 返回适配器因采样和限流丢弃的日志条数，包括通过 With 派生的适配器。
+func (la *Adapter) Dropped() DropStats {
+	return DropStats{
+		Sampled:     la.drop.sampled.Load(),
+		RateLimited: la.drop.limited.Load(),
+	}
+}
+
+// dropper 依次使用采样器和限流器决定是否记录一条日志
+type dropper struct {
+	sampler atomic.Pointer[sampler]
+	limiter atomic.Pointer[rateLimiter]
+	sampled atomic.Uint64
+	limited atomic.Uint64
+}
+
+// allow 报告是否记录 level 级别的消息 msg，Panic 和 Fatal 级别的日志从不丢弃
+func (d *dropper) allow(level Level, msg string) bool {
+	if level > ErrorLevel {
+		return true
+	}
+	if s := d.sampler.Load(); s != nil && !s.allow(level, msg) {
+		d.sampled.Add(1)
+		return false
+	}
+	if r := d.limiter.Load(); r != nil && !r.allow() {
+		d.limited.Add(1)
+		return false
+	}
+	return true
+}
+
+func (d *dropper) setSampling(s Sampling) {
+	if s == (Sampling{}) {
+		d.sampler.Store(nil)
+		return
+	}
+	if cur := d.sampler.Load(); cur != nil && cur.cfg == s {
+		return // 保留计数
+	}
+	d.sampler.Store(newSampler(s))
+}
+
+func (d *dropper) setRateLimit(r RateLimit) {
+	if r == (RateLimit{}) {
+		d.limiter.Store(nil)
+		return
+	}
+	if cur := d.limiter.Load(); cur != nil && cur.cfg == r {
+		return // 保留令牌
+	}
+	d.limiter.Store(newRateLimiter(r))
+}
+
+// samplerSize 采样计数器的个数，级别和消息通过哈希映射到计数器上
+const samplerSize = 1024
+
+// sampler 按级别和消息计数的采样器，与 zap 的采样器类似，
+// 哈希冲突的消息共享计数器，以此限制内存占用
+type sampler struct {
+	cfg      Sampling
+	tick     int64
+	counters [samplerSize]counter
+	now      func() time.Time
+}
+
+func newSampler(cfg Sampling) *sampler {
+	tick := cfg.Tick
+	if tick <= 0 {
+		tick = time.Second
+	}
+	return &sampler{cfg: cfg, tick: int64(tick), now: time.Now}
+}
+
+func (s *sampler) allow(level Level, msg string) bool {
+	var h uint32
+	if msg == "" {
+		h = hashPC(level, callerPC()) // 结构化日志没有消息，按调用位置区分
+	} else {
+		h = hashKey(level, msg)
+	}
+	c := &s.counters[h%samplerSize]
+	n := c.inc(s.now().UnixNano(), s.tick)
+	initial, thereafter := uint64(max(s.cfg.Initial, 0)), uint64(max(s.cfg.Thereafter, 0))
+	return n <= initial || (thereafter > 0 && (n-initial)%thereafter == 0)
+}
+
+// counter 周期计数器
+type counter struct {
+	resetAt atomic.Int64
+	count   atomic.Uint64
+}
+
+// inc 增加计数并返回当前周期内的计数，周期结束后从 1 重新计数
+func (c *counter) inc(now, tick int64) uint64 {
+	resetAt := c.resetAt.Load()
+	if resetAt > now {
+		return c.count.Add(1)
+	}
+	c.count.Store(1)
+	if !c.resetAt.CompareAndSwap(resetAt, now+tick) {
+		return c.count.Add(1) // 其他协程已开始新周期
+	}
+	return 1
+}
+
+// hashKey 计算级别和消息的 FNV-1a 哈希
+func hashKey(level Level, msg string) uint32 {
+	const (
+		offset = 2166136261
+		prime  = 16777619
+	)
+	h := uint32(offset)
+	h = (h ^ uint32(level+2)) * prime
+	for i := 0; i < len(msg); i++ {
+		h = (h ^ uint32(msg[i])) * prime
+	}
+	return h
+}
+
+// hashPC 计算级别和调用位置的 FNV-1a 哈希
+func hashPC(level Level, pc uintptr) uint32 {
+	const (
+		offset = 2166136261
+		prime  = 16777619
+	)
+	h := uint32(offset)
+	h = (h ^ uint32(level+2)) * prime
+	for i := 0; i < 8; i++ {
+		h = (h ^ uint32(uint64(pc)>>(8*i)&0xff)) * prime
+	}
+	return h
+}
+
+// logDir 是本包源文件所在的目录，用于跳过本包内的调用帧
+var logDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callerPC 返回本包之外第一个调用帧的程序计数器，本包的测试文件视为包外
+func callerPC() uintptr {
+	var pcs [16]uintptr
+	n := runtime.Callers(3, pcs[:]) // 跳过 runtime.Callers、callerPC 和 sampler.allow
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if filepath.Dir(f.File) != logDir || strings.HasSuffix(f.File, "_test.go") {
+			return f.PC
+		}
+		if !more {
+			return 0
+		}
+	}
+}
+
+// rateLimiter 令牌桶限流器
+type rateLimiter struct {
+	cfg    RateLimit
+	burst  float64
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newRateLimiter(cfg RateLimit) *rateLimiter {
+	burst := float64(max(cfg.Burst, 1))
+	return &rateLimiter{cfg: cfg, burst: burst, tokens: burst, now: time.Now}
+}
+
+func (r *rateLimiter) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if !r.last.IsZero() && r.cfg.Rate > 0 {
+		elapsed := now.Sub(r.last).Seconds()
+		r.tokens = math.Min(r.burst, r.tokens+elapsed*r.cfg.Rate)
+	}
+	r.last = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
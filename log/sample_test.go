@@ -0,0 +1,153 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// countLogger 统计记录的日志条数
+type countLogger struct {
+	n int
+}
+
+func (l *countLogger) Log(context.Context, Level, string, ...interface{}) { l.n++ }
+func (l *countLogger) Close() error                                       { return nil }
+
+func TestAdapter_Sampling(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	logger := &countLogger{}
+	la := NewAdapter(logger, WithSampling(Sampling{Tick: time.Second, Initial: 2, Thereafter: 3}))
+	la.drop.sampler.Load().now = clock.now
+
+	for i := 0; i < 10; i++ {
+		la.Error("hot loop") // 记录第 1、2、5、8 条
+	}
+	la.Error("other")
+	la.Warn("hot loop") // 级别不同，单独计数
+	if logger.n != 6 {
+		t.Errorf("logged = %d, want 6", logger.n)
+	}
+	if got := la.Dropped(); got != (DropStats{Sampled: 6}) {
+		t.Errorf("Dropped() = %+v, want 6 sampled", got)
+	}
+
+	clock.add(time.Second)
+	la.Error("hot loop")
+	if logger.n != 7 {
+		t.Errorf("logged = %d, want the counter reset after a tick", logger.n)
+	}
+
+	la.SetSampling(Sampling{})
+	for i := 0; i < 10; i++ {
+		la.Error("hot loop")
+	}
+	if logger.n != 17 {
+		t.Errorf("logged = %d, want sampling disabled", logger.n)
+	}
+}
+
+func TestAdapter_SamplingStructured(t *testing.T) {
+	logger := &countLogger{}
+	la := NewAdapter(logger, WithSampling(Sampling{Tick: time.Hour, Initial: 1}))
+
+	for i := 0; i < 10; i++ {
+		la.Errorw("event", "hot", "i", i) // 只记录第 1 条
+	}
+	la.Errorw("event", "other") // 不同的调用位置单独计数
+	if logger.n != 2 {
+		t.Errorf("logged = %d, want one record per call site", logger.n)
+	}
+	if got := la.Dropped(); got != (DropStats{Sampled: 9}) {
+		t.Errorf("Dropped() = %+v, want 9 sampled", got)
+	}
+}
+
+func TestAdapter_RateLimit(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	logger := &countLogger{}
+	la := NewAdapter(logger, WithRateLimit(RateLimit{Rate: 2, Burst: 3}))
+	la.drop.limiter.Load().now = clock.now
+	child := la.With("k", "v") // 与 la 共享令牌桶
+
+	for i := 0; i < 5; i++ {
+		child.Infof("request %d", i)
+	}
+	if logger.n != 3 {
+		t.Errorf("logged = %d, want the burst of 3", logger.n)
+	}
+	clock.add(time.Second)
+	for i := 0; i < 5; i++ {
+		la.Info("after a second")
+	}
+	if logger.n != 5 {
+		t.Errorf("logged = %d, want 2 more tokens after a second", logger.n)
+	}
+	if got := child.Dropped(); got != (DropStats{RateLimited: 5}) {
+		t.Errorf("Dropped() = %+v, want 5 rate limited", got)
+	}
+	la.Debug("disabled level")
+	if got := la.Dropped(); got.RateLimited != 5 {
+		t.Errorf("Dropped() = %+v, disabled levels must not be counted", got)
+	}
+}
+
+func TestAdapter_SamplingBackends(t *testing.T) {
+	sampling := Sampling{Tick: time.Hour, Initial: 3, Thereafter: 10}
+
+	var buf bytes.Buffer
+	slogAdapter := NewAdapter(NewSlogLogger(&buf, InfoLevel), WithSampling(sampling))
+	core, logs := observer.New(zap.InfoLevel)
+	zapAdapter := NewAdapter(&zapLogger{log: zap.New(core)}, WithSampling(sampling))
+
+	for i := 0; i < 100; i++ {
+		slogAdapter.Warnw("k", i)
+		zapAdapter.Warnw("k", i)
+	}
+	// 记录前 3 条，之后每 10 条记录一条
+	if n := strings.Count(buf.String(), "\n"); n != 12 {
+		t.Errorf("slog logged %d records, want 12", n)
+	}
+	if n := logs.Len(); n != 12 {
+		t.Errorf("zap logged %d records, want 12", n)
+	}
+	if s, z := slogAdapter.Dropped(), zapAdapter.Dropped(); s != z || s.Sampled != 88 {
+		t.Errorf("Dropped() = %+v and %+v, want 88 sampled for both", s, z)
+	}
+}
+
+func TestConfig_RateLimits(t *testing.T) {
+	saved := cfg
+	defer SetConfig(saved)
+
+	SetConfig(Config{
+		DefaultLevel: InfoLevel,
+		RateLimits:   map[string]RateLimit{"limited": {Rate: 1, Burst: 1}},
+	})
+	a, b := Named("limited.child"), Named("limited.other")
+	if a.drop.limiter.Load() == nil || a.drop.limiter.Load() == b.drop.limiter.Load() {
+		t.Fatal("components must have their own token bucket")
+	}
+	if Named("unlimited").drop.limiter.Load() != nil {
+		t.Error("unconfigured component is rate limited")
+	}
+
+	SetConfig(Config{DefaultLevel: InfoLevel})
+	if a.drop.limiter.Load() != nil {
+		t.Error("SetConfig did not remove the rate limit")
+	}
+	if _, ok := Dropped()["limited.child"]; !ok {
+		t.Error("Dropped() does not report the component")
+	}
+}
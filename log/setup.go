@@ -0,0 +1,219 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Backend 是默认日志记录器使用的日志后端
+type Backend int
+
+const (
+	// BackendAuto 存在 zap 配置文件时使用 zap，否则使用 slog
+	BackendAuto Backend = iota
+	// BackendZap 使用 zap，没有配置文件时使用默认的控制台配置
+	BackendZap
+	// BackendSlog 使用 slog 文本格式
+	BackendSlog
+)
+
+// String 返回后端的名称
+func (b Backend) String() string {
+	switch b {
+	case BackendZap:
+		return "zap"
+	case BackendSlog:
+		return "slog"
+	default:
+		return "auto"
+	}
+}
+
+// SetupOption 是一个函数类型，用于配置 Setup
+type SetupOption func(*setupOptions)
+
+// setupOptions 是 Setup 的配置，未设置的项从环境变量读取
+type setupOptions struct {
+	backend    Backend
+	level      *Level   // 默认日志级别，为 nil 时从 LOG_LEVEL 读取
+	configFile string   // zap 配置文件，为空时从 LOG_ZAP_CONFIG 读取或按 zapConfigFiles 查找
+	outputs    []string // 没有 zap 配置文件时的输出路径
+	watch      bool
+}
+
+// WithBackend 返回一个 SetupOption，用于选择日志后端，默认为 BackendAuto
+func WithBackend(b Backend) SetupOption {
+	return func(o *setupOptions) { o.backend = b }
+}
+
+// WithDefaultLevel 返回一个 SetupOption，用于设置默认日志级别，默认读取环境变量 LOG_LEVEL
+func WithDefaultLevel(l Level) SetupOption {
+	return func(o *setupOptions) { o.level = &l }
+}
+
+// WithConfigFile 返回一个 SetupOption，用于指定 zap 配置文件，指定的文件不存在或无效时 Setup 返回错误。
+// 默认读取环境变量 LOG_ZAP_CONFIG，未设置时依次查找 zap.config.json、.yaml、.yml 和 .toml，
+// 文件不存在时使用 slog，无效时使用默认的 zap 配置并返回错误
+func WithConfigFile(path string) SetupOption {
+	return func(o *setupOptions) { o.configFile = path }
+}
+
+// WithOutputs 返回一个 SetupOption，用于设置没有 zap 配置文件时的输出路径，
+// 如 "stdout"、"stderr"、文件路径或 rotate:// 滚动日志文件。
+// 默认 slog 输出到标准输出，zap 输出到标准错误；使用 zap 配置文件时以配置文件为准
+func WithOutputs(paths ...string) SetupOption {
+	return func(o *setupOptions) { o.outputs = paths }
+}
+
+// WithWatch 返回一个 SetupOption，用于开关 zap 配置文件的热加载，默认开启
+func WithWatch(enabled bool) SetupOption {
+	return func(o *setupOptions) { o.watch = enabled }
+}
+
+// Setup 初始化并替换默认日志记录器，未设置的选项从环境变量读取。
+// 默认情况下导入本包时会以不带选项的 Setup 初始化；使用 log_noinit 构建标签时不做任何初始化，
+// 默认日志记录器丢弃所有日志，直到调用 Setup。
+// 重复调用时停止之前的配置文件监听，并关闭之前打开的输出；返回错误且未创建日志记录器时保持原样
+func Setup(opts ...SetupOption) error {
+	_, err := setup(opts...)
+	return err
+}
+
+// setup 实现 Setup 并返回实际使用的后端。
+// 只要创建了日志记录器就替换默认日志记录器，同时返回过程中遇到的非致命错误
+func setup(opts ...SetupOption) (Backend, error) {
+	o := setupOptions{watch: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var errs []error
+	var lvl Level
+	if o.level != nil {
+		lvl = *o.level
+	} else if val := os.Getenv("LOG_LEVEL"); len(val) > 0 {
+		if err := lvl.UnmarshalText([]byte(val)); err != nil {
+			errs = append(errs, fmt.Errorf("log: LOG_LEVEL: %w", err))
+		}
+	}
+
+	// 通过 WithConfigFile 指定的配置文件必须有效，环境变量和自动查找的配置文件保持原有的宽松行为
+	explicit := o.configFile != ""
+	if !explicit {
+		o.configFile = findZapConfig()
+		if val, ok := os.LookupEnv("LOG_ZAP_CONFIG"); ok {
+			o.configFile = val
+		}
+	}
+	_, statErr := os.Stat(o.configFile)
+	useFile := explicit || !os.IsNotExist(statErr)
+	if o.backend == BackendAuto {
+		o.backend = BackendSlog
+		if useFile {
+			o.backend = BackendZap
+		}
+	}
+
+	var logger Logger
+	var file string // 使用的 zap 配置文件
+	switch o.backend {
+	case BackendSlog:
+		w := io.Writer(os.Stdout)
+		var closeOutputs func()
+		if len(o.outputs) > 0 {
+			ws, closer, err := zap.Open(o.outputs...)
+			if err != nil {
+				return o.backend, errors.Join(append(errs, fmt.Errorf("log: %w", err))...)
+			}
+			w, closeOutputs = ws, closer
+		}
+		sl := NewSlogLogger(w, lvl).(*slogLogger)
+		sl.close = closeOutputs // 被替换时关闭输出
+		logger = sl
+	case BackendZap:
+		zl, f, err := setupZap(o, lvl, useFile, explicit)
+		if zl == nil {
+			return o.backend, errors.Join(append(errs, err)...)
+		}
+		errs = append(errs, err)
+		zap.RedirectStdLog(zl.log)
+		logger, file = zl, f
+	default:
+		return o.backend, fmt.Errorf("log: unknown backend %d", o.backend)
+	}
+
+	// 创建成功后才停止原有的监听，失败时保持原样
+	errs = append(errs, StopWatch())
+	zapConfigFile.Store(file)
+	if file != "" && o.watch {
+		// 配置文件无效时，修复后也会重新加载
+		errs = append(errs, WatchZapConfig(file, DefaultReloadDebounce))
+	}
+	SetDefaultLogger(logger)
+	if o.level != nil {
+		SetNamedLevel("", lvl, 0)
+	}
+	return o.backend, errors.Join(errs...)
+}
+
+// setupZap 创建 zap 日志记录器，并返回使用的配置文件。useFile 表示使用配置文件，
+// strict 表示配置文件是指定的，无效时只返回错误；否则使用默认的控制台配置，
+// 同时返回日志记录器和配置文件的错误
+func setupZap(o setupOptions, lvl Level, useFile, strict bool) (*zapLogger, string, error) {
+	var file string
+	var fileErr error // 配置文件无效的错误
+	if useFile {
+		abs, err := filepath.Abs(o.configFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("log: %w", err)
+		}
+		logger, err := buildFrom(abs)
+		if err == nil {
+			return logger, abs, nil
+		}
+		if strict {
+			return nil, "", err
+		}
+		file, fileErr = abs, err
+	} //允许配置不存在或者配置错误，此时使用默认配置
+
+	config := zap.NewProductionConfig()
+	config.Level.SetLevel(lvl)
+	config.Encoding = "console"
+	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	if len(o.outputs) > 0 {
+		config.OutputPaths = o.outputs
+	}
+
+	if val, ok := os.LookupEnv("DEBUG"); ok && val != "" {
+		config.Level.SetLevel(zapcore.DebugLevel)
+		config.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	}
+
+	logger, err := buildZapLogger(ZapConfig{Config: config})
+	if err != nil {
+		return nil, "", errors.Join(fileErr, fmt.Errorf("log: %w", err))
+	}
+	return logger, file, fileErr
+}
+
+// Discard 是丢弃所有日志的 Logger，使用 log_noinit 构建标签时作为 Setup 之前的默认日志记录器
+var Discard Logger = discardLogger{}
+
+type discardLogger struct{}
+
+func (discardLogger) Log(context.Context, Level, string, ...interface{}) {}
+func (discardLogger) Close() error                                       { return nil }
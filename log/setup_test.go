@@ -0,0 +1,184 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// restoreDefault 在测试结束后恢复默认日志记录器、全局配置和配置文件监听
+func restoreDefault(t *testing.T) {
+	saved := root.load().Logger
+	savedCfg := GetConfig()
+	t.Cleanup(func() {
+		_ = StopWatch()
+		zapConfigFile.Store("")
+		SetDefaultLogger(saved)
+		SetConfig(savedCfg)
+	})
+}
+
+func readLog(t *testing.T, file string) string {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSetup_Slog(t *testing.T) {
+	restoreDefault(t)
+	out := filepath.Join(t.TempDir(), "app.log")
+
+	err := Setup(WithBackend(BackendSlog), WithDefaultLevel(WarnLevel), WithOutputs(out))
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	Info("dropped")
+	Warn("kept")
+	Named("setup.test").Error("named")
+
+	got := readLog(t, out)
+	for _, want := range []string{`msg=kept`, `msg=named logger=setup.test`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output = %q, want %q", got, want)
+		}
+	}
+	if strings.Contains(got, "dropped") {
+		t.Errorf("output = %q, want info filtered", got)
+	}
+	if c := GetConfig(); c.DefaultLevel != WarnLevel {
+		t.Errorf("DefaultLevel = %v, want warn", c.DefaultLevel)
+	}
+}
+
+func TestSetup_FlushKeepsOutputs(t *testing.T) {
+	restoreDefault(t)
+	out := filepath.Join(t.TempDir(), "app.log")
+	if err := Setup(WithBackend(BackendSlog), WithDefaultLevel(InfoLevel), WithOutputs(out)); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	Info("before flush")
+	if err := Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	Info("after flush")
+
+	async := NewAsyncLogger(root.load().Logger)
+	defer async.Close()
+	async.Log(context.Background(), InfoLevel, "async")
+	if err := async.Flush(); err != nil {
+		t.Fatalf("AsyncLogger.Flush() error = %v", err)
+	}
+	Info("after async flush")
+
+	got := readLog(t, out)
+	for _, want := range []string{`msg="after flush"`, `msg=async`, `msg="after async flush"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSetup_ZapConfigFile(t *testing.T) {
+	restoreDefault(t)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "app.log")
+	file := writeConfig(t, dir, "custom.yaml", "level: info\nencoding: json\noutputPaths: [\""+filepath.ToSlash(out)+"\"]\n")
+
+	backend, err := setup(WithConfigFile(file), WithWatch(false), WithOutputs("stdout"))
+	if err != nil || backend != BackendZap {
+		t.Fatalf("setup() = %v, %v, want zap", backend, err)
+	}
+	watcherMu.Lock()
+	watching := watcher != nil
+	watcherMu.Unlock()
+	if watching {
+		t.Error("watching with WithWatch(false)")
+	}
+	Error("from zap")
+	Flush()
+	if got := readLog(t, out); !strings.Contains(got, `"msg":"from zap"`) {
+		t.Errorf("output = %q, want the config file outputs", got)
+	}
+}
+
+func TestSetup_InvalidConfigFile(t *testing.T) {
+	restoreDefault(t)
+	before := root.load().Logger
+	watcherMu.Lock()
+	beforeWatcher := watcher
+	watcherMu.Unlock()
+	dir := t.TempDir()
+	for _, file := range []string{
+		writeConfig(t, dir, "zap.config.yaml", "level: loud\n"),
+		filepath.Join(dir, "missing.json"),
+	} {
+		if err := Setup(WithConfigFile(file)); err == nil {
+			t.Errorf("Setup(%s) error = nil", filepath.Base(file))
+		}
+		if root.load().Logger != before {
+			t.Errorf("Setup(%s) replaced the default logger", filepath.Base(file))
+		}
+		watcherMu.Lock()
+		w := watcher
+		watcherMu.Unlock()
+		if w != beforeWatcher {
+			t.Errorf("Setup(%s) changed the config watcher", filepath.Base(file))
+		}
+	}
+}
+
+func TestSetup_ClosesReplacedOutputs(t *testing.T) {
+	restoreDefault(t)
+	dir := t.TempDir()
+	if err := Setup(WithBackend(BackendSlog), WithOutputs(filepath.Join(dir, "a.log"))); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	sl, ok := root.load().Logger.(*slogLogger)
+	if !ok || sl.close == nil {
+		t.Fatalf("default logger = %T, want a slog logger closing its outputs", root.load().Logger)
+	}
+	closed := false
+	closeOutputs := sl.close
+	sl.close = func() {
+		closed = true
+		closeOutputs()
+	}
+	if err := Setup(WithBackend(BackendSlog), WithOutputs(filepath.Join(dir, "b.log"))); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	if !closed {
+		t.Error("the outputs of the replaced logger were not closed")
+	}
+}
+
+func TestSetup_Auto(t *testing.T) {
+	restoreDefault(t)
+	t.Setenv("LOG_ZAP_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("LOG_LEVEL", "error")
+	backend, err := setup(WithOutputs(filepath.Join(t.TempDir(), "app.log")))
+	if err != nil || backend != BackendSlog {
+		t.Errorf("setup() = %v, %v, want slog", backend, err)
+	}
+
+	// 无效的配置文件使用默认的 zap 配置，并返回配置文件的错误
+	t.Setenv("LOG_ZAP_CONFIG", writeConfig(t, t.TempDir(), "zap.config.yaml", "level: loud\n"))
+	before := root.load().Logger
+	backend, err = setup(WithOutputs(filepath.Join(t.TempDir(), "app.log")), WithWatch(false))
+	if err == nil || !strings.Contains(err.Error(), "unrecognized level") || backend != BackendZap {
+		t.Errorf("setup() = %v, %v, want zap with the config error", backend, err)
+	}
+	if root.load().Logger == before {
+		t.Error("setup() kept the previous logger, want the default zap config")
+	}
+}
@@ -0,0 +1,273 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// zapConfigFiles 未设置 LOG_ZAP_CONFIG 时依次查找的 zap 配置文件
+var zapConfigFiles = []string{"zap.config.json", "zap.config.yaml", "zap.config.yml", "zap.config.toml"}
+
+// findZapConfig 返回 zapConfigFiles 中第一个存在的文件，都不存在时返回 zap.config.json
+func findZapConfig() string {
+	for _, f := range zapConfigFiles {
+		if _, err := os.Stat(f); err == nil {
+			return f
+		}
+	}
+	return zapConfigFiles[0]
+}
+
+// LoadZapConfig 读取并校验 zap 配置文件，按扩展名(.json/.yaml/.yml/.toml)选择格式
+// 所有格式统一使用 json 标签映射字段，未设置的字段使用 zap.NewProductionConfig 的默认值，
+// 未知的字段被忽略，与只支持 JSON 时一致
+// 返回的错误包含文件名，以及出错的行号或字段路径，可用于在部署前检查配置文件
+func LoadZapConfig(file string) (ZapConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ZapConfig{}, fmt.Errorf("log: %w", err)
+	}
+	c, err := ParseZapConfig(filepath.Ext(file), data)
+	if err != nil {
+		return ZapConfig{}, fmt.Errorf("log: %s: %w", file, err)
+	}
+	return c, nil
+}
+
+// ParseZapConfig 按扩展名解析并校验 zap 配置数据，规则与 LoadZapConfig 相同
+func ParseZapConfig(ext string, data []byte) (ZapConfig, error) {
+	m, err := decodeConfigMap(strings.ToLower(ext), data)
+	if err != nil {
+		return ZapConfig{}, err
+	}
+	if err := checkConfigLevels(m); err != nil {
+		return ZapConfig{}, err
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return ZapConfig{}, err
+	}
+
+	c := ZapConfig{
+		Config: zap.NewProductionConfig(),
+		Named:  make(map[string]Level),
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return ZapConfig{}, err
+	}
+	if c.Named == nil {
+		c.Named = make(map[string]Level)
+	}
+	return c, c.Validate()
+}
+
+// decodeConfigMap 将配置数据解析为 map
+func decodeConfigMap(ext string, data []byte) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	switch ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			var se *json.SyntaxError
+			if errors.As(err, &se) {
+				line, col := position(data, se.Offset)
+				return nil, fmt.Errorf("line %d, column %d: %w", line, col, err)
+			}
+			return nil, err
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file format %q, want .json, .yaml, .yml or .toml", ext)
+	}
+	if m == nil {
+		m = make(map[string]interface{}) // 空文件
+	}
+	return m, nil
+}
+
+// position 返回 data 中偏移量 offset 之前最后一个字符所在的行号和列号，从 1 开始
+func position(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n') - 1
+	return line, max(col, 1)
+}
+
+// checkConfigLevels 校验 level 和 named 中的日志级别，以便错误中包含字段路径
+func checkConfigLevels(m map[string]interface{}) error {
+	check := func(field string, v interface{}) error {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: level must be a string, got %v", field, v)
+		}
+		var l Level
+		if err := l.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		return nil
+	}
+
+	var errs []error
+	if v, ok := m["level"]; ok {
+		errs = append(errs, check("level", v))
+	}
+	if v, ok := m["named"]; ok && v != nil {
+		named, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("named: must be a map of component names to levels, got %v", v)
+		}
+		for k, lvl := range named {
+			errs = append(errs, check("named."+k, lvl))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate 校验配置的编码、输出路径、组件名称、采样和滚动日志文件设置，
+// 返回所有发现的错误，每个错误以出错的字段路径开头
+func (c ZapConfig) Validate() error {
+	var errs []error
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Level == (zap.AtomicLevel{}) {
+		add("level", "missing level")
+	}
+	switch c.Encoding {
+	case "json", "console":
+	default:
+		add("encoding", "unsupported encoding %q, want json or console", c.Encoding)
+	}
+	if len(c.OutputPaths) == 0 && c.Rotate == nil {
+		add("outputPaths", "no output, set outputPaths or rotate")
+	}
+	for i, p := range c.OutputPaths {
+		if err := checkOutputPath(p); err != nil {
+			add(fmt.Sprintf("outputPaths[%d]", i), "%v", err)
+		}
+	}
+	for i, p := range c.ErrorOutputPaths {
+		if err := checkOutputPath(p); err != nil {
+			add(fmt.Sprintf("errorOutputPaths[%d]", i), "%v", err)
+		}
+	}
+	for name := range c.Named {
+		if name == "" || strings.TrimSpace(name) != name || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") {
+			add("named", "invalid component name %q", name)
+		}
+	}
+	if s := c.Sampling; s != nil && (s.Initial < 0 || s.Thereafter < 0) {
+		add("sampling", "initial and thereafter must not be negative")
+	}
+	if r := c.Rotate; r != nil {
+		if r.Filename == "" {
+			add("rotate.filename", "missing filename")
+		} else if err := checkCreatableDir(r.Filename); err != nil {
+			add("rotate.filename", "%v", err)
+		}
+		if r.MaxSize < 0 {
+			add("rotate.maxSize", "must not be negative")
+		}
+		if r.MaxBackups < 0 {
+			add("rotate.maxBackups", "must not be negative")
+		}
+		if r.Interval < 0 {
+			add("rotate.interval", "must not be negative")
+		}
+		if r.MaxAge < 0 {
+			add("rotate.maxAge", "must not be negative")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkOutputPath 校验 zap 输出路径，文件路径所在的目录必须存在，滚动日志文件的目录会在打开时创建
+// 通过 zap.RegisterSink 注册的其他协议无法校验，直接通过
+func checkOutputPath(p string) error {
+	if p == "" {
+		return errors.New("empty path")
+	}
+	if p == "stdout" || p == "stderr" {
+		return nil
+	}
+	u, err := url.Parse(p)
+	if err != nil || len(u.Scheme) <= 1 { // 不是 URL，或是 Windows 盘符
+		return checkDir(p)
+	}
+	switch u.Scheme {
+	case "file":
+		return checkDir(filepath.FromSlash(u.Path))
+	case RotateScheme:
+		rc, err := parseRotateURL(u)
+		if err != nil {
+			return err
+		}
+		return checkCreatableDir(rc.Filename)
+	default:
+		return nil
+	}
+}
+
+// checkDir 检查文件 path 所在的目录是否存在
+func checkDir(path string) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("directory of %q: %w", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("directory of %q: %s is not a directory", path, dir)
+	}
+	return nil
+}
+
+// checkCreatableDir 检查文件 path 所在的目录能否创建，与 RotatingFile 打开时一致，
+// 不存在的目录会被创建，因此只要求最近的已存在的上级是目录
+func checkCreatableDir(path string) error {
+	dir := filepath.Dir(path)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("directory of %q: %s is not a directory", path, dir)
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("directory of %q: %w", path, err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2023 Enoch <lanxenet@gmail.com>. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ */
+
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadZapConfig_Formats(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.ToSlash(filepath.Join(dir, "app.log"))
+	files := []string{
+		writeConfig(t, dir, "zap.config.json", `{
+  "level": "warn",
+  "comment": "unknown fields are ignored",
+  "encoding": "console",
+  "outputPaths": ["stdout", "`+out+`"],
+  "encoderConfig": {"messageKey": "message", "levelEncoder": "capital"},
+  "named": {"db": "debug"},
+  "rotate": {"filename": "`+out+`", "maxSize": 10, "interval": "24h"}
+}`),
+		writeConfig(t, dir, "zap.config.yaml", `
+level: warn
+encoding: console
+outputPaths: [stdout, "`+out+`"]
+encoderConfig:
+  messageKey: message
+  levelEncoder: capital
+named:
+  db: debug
+rotate:
+  filename: "`+out+`"
+  maxSize: 10
+  interval: 24h
+`),
+		writeConfig(t, dir, "zap.config.toml", `
+level = "warn"
+encoding = "console"
+outputPaths = ["stdout", "`+out+`"]
+
+[encoderConfig]
+messageKey = "message"
+levelEncoder = "capital"
+
+[named]
+db = "debug"
+
+[rotate]
+filename = "`+out+`"
+maxSize = 10
+interval = "24h"
+`),
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Ext(file), func(t *testing.T) {
+			c, err := LoadZapConfig(file)
+			if err != nil {
+				t.Fatalf("LoadZapConfig() error = %v", err)
+			}
+			if c.Level.Level() != WarnLevel || c.Encoding != "console" || c.EncoderConfig.MessageKey != "message" {
+				t.Errorf("config = %v %s %s, want warn console message", c.Level, c.Encoding, c.EncoderConfig.MessageKey)
+			}
+			if !reflect.DeepEqual(c.OutputPaths, []string{"stdout", out}) {
+				t.Errorf("outputPaths = %v", c.OutputPaths)
+			}
+			if c.Named["db"] != DebugLevel {
+				t.Errorf("named = %v, want db at debug", c.Named)
+			}
+			if c.Rotate == nil || c.Rotate.MaxSize != 10 || c.Rotate.Interval != Duration(24*time.Hour) {
+				t.Errorf("rotate = %+v", c.Rotate)
+			}
+			if c.Sampling == nil || c.Sampling.Initial != 100 {
+				t.Errorf("sampling = %+v, want the production default", c.Sampling)
+			}
+		})
+	}
+}
+
+func TestLoadZapConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.ToSlash(filepath.Join(dir, "missing", "app.log"))
+	blocked := filepath.ToSlash(writeConfig(t, dir, "blocker", "") + "/sub/app.log") // 上级是普通文件，无法创建目录
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+	}{
+		{"level", "a.json", `{"level": "loud"}`, []string{"a.json: level: unrecognized level"}},
+		{"named", "b.yaml", "named:\n  db: loud\n  cache: 1\n", []string{`named.db: unrecognized level: "loud"`, "named.cache: level must be a string"}},
+		{"syntax", "c.json", "{\n  \"level\": \"info\",\n}", []string{"line 3, column 1"}},
+		{"yaml syntax", "d.yml", "level: [info\n", []string{"d.yml: yaml: line"}},
+		{"toml syntax", "e.toml", "level = \n", []string{"e.toml: toml:"}},
+		{"encoding", "g.yaml", "encoding: xml\n", []string{`encoding: unsupported encoding "xml"`}},
+		{"outputs", "h.toml", `outputPaths = ["stdout", "` + missing + `", "rotate://` + blocked + `"]
+errorOutputPaths = ["file://` + missing + `"]
+[rotate]
+maxSize = -1
+`, []string{"outputPaths[1]: directory of", "outputPaths[2]: directory of", "errorOutputPaths[0]: directory of",
+			"rotate.filename: missing filename", "rotate.maxSize: must not be negative"}},
+		{"format", "i.ini", "level=info", []string{`unsupported file format ".ini"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadZapConfig(writeConfig(t, dir, tt.file, tt.content))
+			if err == nil {
+				t.Fatal("LoadZapConfig() error = nil")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadZapConfig() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadZapConfig_RotateCreatesDir(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.ToSlash(filepath.Join(dir, "logs", "daily", "app.log"))
+	file := writeConfig(t, dir, "zap.config.yaml", "outputPaths: [\"rotate://"+out+"\"]\nrotate:\n  filename: \""+out+"\"\n")
+	if _, err := LoadZapConfig(file); err != nil {
+		t.Errorf("LoadZapConfig() error = %v, want the missing rotate directory accepted", err)
+	}
+}
+
+func TestBuildFrom_Invalid(t *testing.T) {
+	file := writeConfig(t, t.TempDir(), "zap.config.yaml", "level: loud\n")
+	if logger, err := buildFrom(file); err == nil || logger != nil {
+		t.Errorf("buildFrom() = %v, %v, want an error", logger, err)
+	}
+}